@@ -0,0 +1,238 @@
+// Package activitypub exposes the streams of an ActivityStream as ActivityPub outboxes and inboxes.
+//
+// Following the convention of the README every actor has two streams:
+// 		the outbox "ACTOR_ID-out", served as OrderedCollection under /ACTOR_ID/outbox
+// 		the inbox "ACTOR_ID", which receives signed activities posted to /ACTOR_ID/inbox
+package activitypub
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/chrisport/go-activitystream/activitystream"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ContentType is the media type of ActivityPub documents
+	ContentType = "application/activity+json"
+	// DefaultPageSize is the size of an outbox page if the request does not specify one
+	DefaultPageSize = 20
+	// MaxPageSize is the largest size of an outbox page which can be requested
+	MaxPageSize = 100
+	// MaxActivitySize is the maximum size in bytes of an activity posted to an inbox
+	MaxActivitySize = 1 << 20
+)
+
+var (
+	errInvalidPageSize = errors.New("page size s must be a number between 1 and " + strconv.Itoa(MaxPageSize))
+	errInvalidPivot    = errors.New("pivots after and before must be numbers")
+)
+
+// OutboxStreamID returns the ID of the stream containing the activities of the actor
+func OutboxStreamID(actorId string) string {
+	return actorId + "-out"
+}
+
+// InboxStreamID returns the ID of the stream containing the activities addressed to the actor
+func InboxStreamID(actorId string) string {
+	return actorId
+}
+
+// OrderedCollection is the ActivityPub collection of an outbox, its items are served in pages starting with First.
+type OrderedCollection struct {
+	Context interface{} `json:"@context,omitempty"`
+	Id      string      `json:"id"`
+	Type    string      `json:"type"`
+	First   string      `json:"first,omitempty"`
+}
+
+// OrderedCollectionPage is a page of an outbox. Prev and Next are derived from the pagination tokens of the stream.
+type OrderedCollectionPage struct {
+	Context      interface{}                  `json:"@context,omitempty"`
+	Id           string                       `json:"id"`
+	Type         string                       `json:"type"`
+	PartOf       string                       `json:"partOf"`
+	Prev         string                       `json:"prev,omitempty"`
+	Next         string                       `json:"next,omitempty"`
+	OrderedItems []activitystream.AS2Activity `json:"orderedItems"`
+}
+
+// Server is a http.Handler serving the outboxes and inboxes of all actors of an ActivityStream.
+type Server struct {
+	Stream activitystream.ActivityStream
+	// BaseURL is the URL the Server is served at, it is used to create the IDs of collections and pages
+	BaseURL string
+	// Scorer must be the Scorer of Stream, nil stands for the default TimeScorer
+	Scorer activitystream.Scorer
+	// Keys are the keys accepted for signatures of inbox requests, identified by their key ID.
+	// Requests which are not signed by one of these keys are rejected.
+	Keys map[string]PublicKey
+	// FanOut returns the streams an incoming activity is added to in addition to the recipient's inbox, e.g. the
+	// inboxes of the recipient's followers. It is optional.
+	FanOut func(recipientId string, activity activitystream.Activity) []string
+}
+
+// ServeHTTP serves GET requests to /ACTOR_ID/outbox and POST requests to /ACTOR_ID/inbox
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+	actorId, endpoint := path[:i], path[i+1:]
+
+	switch {
+	case endpoint == "outbox" && r.Method == "GET":
+		s.serveOutbox(w, r, actorId)
+	case endpoint == "inbox" && r.Method == "POST":
+		s.serveInbox(w, r, actorId)
+	case endpoint == "outbox" || endpoint == "inbox":
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveOutbox(w http.ResponseWriter, r *http.Request, actorId string) {
+	collectionId := s.BaseURL + "/" + actorId + "/outbox"
+	query := r.URL.Query()
+	if query.Get("s") == "" && query.Get("after") == "" && query.Get("before") == "" {
+		writeJSON(w, OrderedCollection{
+			Context: activitystream.AS2Context,
+			Id:      collectionId,
+			Type:    "OrderedCollection",
+			First:   collectionId + "?s=" + strconv.Itoa(DefaultPageSize),
+		})
+		return
+	}
+
+	size, pivot, direction, err := parsePage(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	streamId := OutboxStreamID(actorId)
+	// the outbox is public, so it only contains the activities visible to an anonymous viewer
+	activities, err := s.Stream.GetStreamForViewer(streamId, "", size, pivot, direction)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	scorer := s.Scorer
+	if scorer == nil {
+		scorer = activitystream.TimeScorer
+	}
+	prev, next := activitystream.CreateScoredTokens(size, direction, activities, streamId, scorer)
+	page := OrderedCollectionPage{
+		Context:      activitystream.AS2Context,
+		Id:           collectionId + "?" + r.URL.RawQuery,
+		Type:         "OrderedCollectionPage",
+		PartOf:       collectionId,
+		OrderedItems: make([]activitystream.AS2Activity, len(activities)),
+	}
+	if prev != "" {
+		page.Prev = collectionId + prev
+	}
+	if next != "" {
+		page.Next = collectionId + next
+	}
+	for i := range activities {
+		page.OrderedItems[i] = activities[i].ToAS2()
+		page.OrderedItems[i].Context = nil
+		page.OrderedItems[i].Bto = nil
+		page.OrderedItems[i].Bcc = nil
+	}
+	writeJSON(w, page)
+}
+
+func (s *Server) serveInbox(w http.ResponseWriter, r *http.Request, actorId string) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxActivitySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key, err := Verify(r, body, s.Keys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var activity activitystream.Activity
+	if err := activity.UnmarshalAS2(body); err != nil || activity.Id == "" {
+		http.Error(w, "body is not a valid activity", http.StatusBadRequest)
+		return
+	}
+	if activity.Actor.Id != key.Owner {
+		http.Error(w, "activity was not signed by its actor", http.StatusForbidden)
+		return
+	}
+	if !sameHost(activity.Id, key.Owner) {
+		http.Error(w, "activity ID must be an IRI on the host of its actor", http.StatusBadRequest)
+		return
+	}
+
+	// the sender must not place its activity above newer ones by publishing it in the future
+	if received := time.Now().UTC(); activity.Published.IsZero() || activity.Published.After(received) {
+		activity.Published = received
+	}
+
+	streamIds := []string{InboxStreamID(actorId)}
+	if s.FanOut != nil {
+		streamIds = append(streamIds, s.FanOut(actorId, activity)...)
+	}
+	for _, err := range s.Stream.AddToStreams(activity, streamIds...) {
+		// activities which are not kept by a full stream or are too old have still been received
+		if !errors.Is(err, activitystream.ErrStreamFull) && !errors.Is(err, activitystream.ErrExpired) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sameHost returns whether the ID is an absolute IRI on the host of the owner, so that remote activities cannot take
+// the keys of local streams and activities
+func sameHost(id, owner string) bool {
+	i, err := url.Parse(id)
+	if err != nil || !i.IsAbs() || i.Host == "" {
+		return false
+	}
+	o, err := url.Parse(owner)
+	return err == nil && strings.EqualFold(i.Host, o.Host)
+}
+
+// parsePage reads the pagination tokens created by activitystream.CreateTokens
+func parsePage(query url.Values) (size, pivot int, direction activitystream.Direction, err error) {
+	size = DefaultPageSize
+	if s := query.Get("s"); s != "" {
+		if size, err = strconv.Atoi(s); err != nil || size <= 0 || size > MaxPageSize {
+			return 0, 0, direction, errInvalidPageSize
+		}
+	}
+
+	direction = activitystream.After
+	p := query.Get("after")
+	if before := query.Get("before"); before != "" {
+		direction = activitystream.Before
+		p = before
+	}
+	if p != "" {
+		if pivot, err = strconv.Atoi(p); err != nil {
+			return 0, 0, direction, errInvalidPivot
+		}
+	}
+	return size, pivot, direction, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", ContentType)
+	json.NewEncoder(w).Encode(v)
+}
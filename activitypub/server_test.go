@@ -0,0 +1,325 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/chrisport/go-activitystream/activitystream"
+	"github.com/chrisport/go-activitystream/redisstream"
+	redis "github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo/bson"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	testing "testing"
+	"time"
+)
+
+const (
+	skipIntegrationTests = false
+	address              = ":6379"
+	protocol             = "tcp"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]PublicKey{"KEY_ID": {Owner: "ACTOR_ID", Key: &privateKey.PublicKey}}
+	body := []byte(`{"type":"Create"}`)
+
+	Convey("Subject: Test HTTP signatures", t, func() {
+		req := httptest.NewRequest("POST", "http://example.com/RECIPIENT_ID/inbox", bytes.NewReader(body))
+		So(Sign(req, body, "KEY_ID", privateKey), ShouldBeNil)
+
+		Convey("When a request is signed with a known key", func() {
+			Convey("It should return the key", func() {
+				key, err := Verify(req, body, keys)
+				So(err, ShouldBeNil)
+				So(key.Owner, ShouldEqual, "ACTOR_ID")
+			})
+		})
+		Convey("When the body of a signed request is changed", func() {
+			Convey("It should fail", func() {
+				_, err := Verify(req, []byte(`{"type":"Delete"}`), keys)
+				So(err, ShouldEqual, ErrInvalidSignature)
+			})
+		})
+		Convey("When a signed header is changed", func() {
+			req.Header.Set("Date", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+
+			Convey("It should fail", func() {
+				_, err := Verify(req, body, keys)
+				So(err, ShouldEqual, ErrInvalidSignature)
+			})
+		})
+		Convey("When a request is signed with an unknown key", func() {
+			Convey("It should fail", func() {
+				_, err := Verify(req, body, map[string]PublicKey{})
+				So(err, ShouldEqual, ErrUnknownKey)
+			})
+		})
+		Convey("When a request is not signed", func() {
+			req.Header.Del("Signature")
+
+			Convey("It should fail", func() {
+				_, err := Verify(req, body, keys)
+				So(err, ShouldEqual, ErrMissingSignature)
+			})
+		})
+	})
+}
+
+func TestServer(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := redisstream.NewRedisActivityStream(redisstream.WithAddr(protocol, address))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{
+		Stream:  stream,
+		BaseURL: "http://example.com",
+		Keys:    map[string]PublicKey{"http://remote.com/alice#key": {Owner: "http://remote.com/alice", Key: &privateKey.PublicKey}},
+		FanOut: func(recipientId string, activity activitystream.Activity) []string {
+			return []string{"FOLLOWER_ID"}
+		},
+	}
+
+	start := time.Now().UTC()
+	testActivities := make([]activitystream.Activity, 3)
+	for i := range testActivities {
+		testActivities[i] = createTestActivity()
+		testActivities[i].Published = start.Add(time.Duration(i) * time.Millisecond)
+	}
+	cleanUp := func() {
+		removeFromRedis(OutboxStreamID("ACTOR_ID"), InboxStreamID("ACTOR_ID"), "FOLLOWER_ID")
+		for i := range testActivities {
+			removeFromRedis(testActivities[i].Id)
+		}
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test ActivityPub Server", t, func() {
+		cleanUp()
+		for i := range testActivities {
+			So(server.Stream.AddToStreams(testActivities[i], OutboxStreamID("ACTOR_ID")), ShouldBeEmpty)
+		}
+
+		Convey("When the outbox is requested", func() {
+			res := httptest.NewRecorder()
+			server.ServeHTTP(res, httptest.NewRequest("GET", "/ACTOR_ID/outbox", nil))
+
+			Convey("It should return an OrderedCollection pointing to the first page", func() {
+				var collection OrderedCollection
+				So(res.Code, ShouldEqual, http.StatusOK)
+				So(res.Header().Get("Content-Type"), ShouldEqual, ContentType)
+				So(json.Unmarshal(res.Body.Bytes(), &collection), ShouldBeNil)
+				So(collection.Type, ShouldEqual, "OrderedCollection")
+				So(collection.Id, ShouldEqual, "http://example.com/ACTOR_ID/outbox")
+				So(collection.First, ShouldEqual, "http://example.com/ACTOR_ID/outbox?s=20")
+			})
+		})
+
+		Convey("When pages of the outbox are requested", func() {
+			res := httptest.NewRecorder()
+			server.ServeHTTP(res, httptest.NewRequest("GET", "/ACTOR_ID/outbox?s=2", nil))
+			var page OrderedCollectionPage
+			So(json.Unmarshal(res.Body.Bytes(), &page), ShouldBeNil)
+
+			Convey("It should return the newest activities and link the next page by pivot", func() {
+				So(page.Type, ShouldEqual, "OrderedCollectionPage")
+				So(len(page.OrderedItems), ShouldEqual, 2)
+				So(page.OrderedItems[0].Id, ShouldEqual, testActivities[2].Id)
+				So(page.Next, ShouldEqual, "http://example.com/ACTOR_ID/outbox?s=2&after="+strconv.Itoa(testActivities[1].Score()))
+
+				res := httptest.NewRecorder()
+				server.ServeHTTP(res, httptest.NewRequest("GET", page.Next[len("http://example.com"):], nil))
+				var next OrderedCollectionPage
+				So(json.Unmarshal(res.Body.Bytes(), &next), ShouldBeNil)
+				So(len(next.OrderedItems), ShouldEqual, 1)
+				So(next.OrderedItems[0].Id, ShouldEqual, testActivities[0].Id)
+				So(next.Next, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the outbox contains an activity which is not public", func() {
+			activity := createTestActivity()
+			activity.To = []string{"FRIEND_ID"}
+			defer removeFromRedis(activity.Id)
+			So(server.Stream.AddToStreams(activity, OutboxStreamID("ACTOR_ID")), ShouldBeEmpty)
+
+			res := httptest.NewRecorder()
+			server.ServeHTTP(res, httptest.NewRequest("GET", "/ACTOR_ID/outbox?s=2", nil))
+			var page OrderedCollectionPage
+			So(json.Unmarshal(res.Body.Bytes(), &page), ShouldBeNil)
+
+			Convey("It should not be served", func() {
+				So(len(page.OrderedItems), ShouldEqual, 2)
+				So(page.OrderedItems[0].Id, ShouldEqual, testActivities[2].Id)
+			})
+		})
+
+		Convey("When a signed activity is posted to an inbox", func() {
+			activity := createTestActivity()
+			activity.Id = "http://remote.com/activities/" + activity.Id
+			activity.Actor.Id = "http://remote.com/alice"
+			defer removeFromRedis(activity.Id)
+			body, err := activity.MarshalAS2()
+			So(err, ShouldBeNil)
+
+			req := httptest.NewRequest("POST", "/ACTOR_ID/inbox", bytes.NewReader(body))
+			So(Sign(req, body, "http://remote.com/alice#key", privateKey), ShouldBeNil)
+			res := httptest.NewRecorder()
+			server.ServeHTTP(res, req)
+
+			Convey("It should be added to the inbox and fanned out", func() {
+				So(res.Code, ShouldEqual, http.StatusAccepted)
+				for _, streamId := range []string{InboxStreamID("ACTOR_ID"), "FOLLOWER_ID"} {
+					stream, err := server.Stream.GetStream(streamId, 0, 0, activitystream.After)
+					So(err, ShouldBeNil)
+					So(len(stream), ShouldEqual, 1)
+					So(stream[0].Id, ShouldEqual, activity.Id)
+				}
+			})
+		})
+
+		Convey("When a signed activity published in the future is posted to an inbox", func() {
+			activity := createTestActivity()
+			activity.Id = "http://remote.com/activities/" + activity.Id
+			activity.Actor.Id = "http://remote.com/alice"
+			activity.Published = time.Now().UTC().Add(24 * time.Hour)
+			defer removeFromRedis(activity.Id)
+			body, err := activity.MarshalAS2()
+			So(err, ShouldBeNil)
+
+			req := httptest.NewRequest("POST", "/ACTOR_ID/inbox", bytes.NewReader(body))
+			So(Sign(req, body, "http://remote.com/alice#key", privateKey), ShouldBeNil)
+			res := httptest.NewRecorder()
+			server.ServeHTTP(res, req)
+
+			Convey("It should be scored by the time it was received", func() {
+				So(res.Code, ShouldEqual, http.StatusAccepted)
+				stream, err := server.Stream.GetStream(InboxStreamID("ACTOR_ID"), 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 1)
+				So(stream[0].Published.After(time.Now().UTC()), ShouldBeFalse)
+			})
+		})
+
+		Convey("When a signed activity is posted to an inbox which does not keep it", func() {
+			stream.SetRetentionPolicies(activitystream.RetentionPolicy{Pattern: InboxStreamID("ACTOR_ID"), MaxAge: time.Hour})
+			defer stream.SetRetentionPolicies()
+			activity := createTestActivity()
+			activity.Id = "http://remote.com/activities/" + activity.Id
+			activity.Actor.Id = "http://remote.com/alice"
+			activity.Published = time.Now().UTC().Add(-2 * time.Hour)
+			defer removeFromRedis(activity.Id)
+			body, err := activity.MarshalAS2()
+			So(err, ShouldBeNil)
+
+			req := httptest.NewRequest("POST", "/ACTOR_ID/inbox", bytes.NewReader(body))
+			So(Sign(req, body, "http://remote.com/alice#key", privateKey), ShouldBeNil)
+			res := httptest.NewRecorder()
+			server.ServeHTTP(res, req)
+
+			Convey("It should still be accepted and fanned out", func() {
+				So(res.Code, ShouldEqual, http.StatusAccepted)
+				inbox, err := server.Stream.GetStream(InboxStreamID("ACTOR_ID"), 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(inbox), ShouldEqual, 0)
+				followers, err := server.Stream.GetStream("FOLLOWER_ID", 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(followers), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When an activity is posted to an inbox without signature", func() {
+			activity := createTestActivity()
+			body, err := activity.MarshalAS2()
+			So(err, ShouldBeNil)
+			res := httptest.NewRecorder()
+			server.ServeHTTP(res, httptest.NewRequest("POST", "/ACTOR_ID/inbox", bytes.NewReader(body)))
+
+			Convey("It should be rejected", func() {
+				So(res.Code, ShouldEqual, http.StatusUnauthorized)
+				stream, err := server.Stream.GetStream(InboxStreamID("ACTOR_ID"), 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When an activity is posted to an inbox signed by another actor", func() {
+			activity := createTestActivity()
+			body, err := activity.MarshalAS2()
+			So(err, ShouldBeNil)
+			req := httptest.NewRequest("POST", "/ACTOR_ID/inbox", bytes.NewReader(body))
+			So(Sign(req, body, "http://remote.com/alice#key", privateKey), ShouldBeNil)
+			res := httptest.NewRecorder()
+			server.ServeHTTP(res, req)
+
+			Convey("It should be rejected", func() {
+				So(res.Code, ShouldEqual, http.StatusForbidden)
+			})
+		})
+
+		Convey("When a signed activity with an ID which is not on the host of its actor is posted to an inbox", func() {
+			for _, id := range []string{"ACTOR_ID-out", testActivities[0].Id, "/activities/1", "http://local.com/activities/1"} {
+				activity := createTestActivity()
+				activity.Id = id
+				activity.Actor.Id = "http://remote.com/alice"
+				body, err := activity.MarshalAS2()
+				So(err, ShouldBeNil)
+				req := httptest.NewRequest("POST", "/ACTOR_ID/inbox", bytes.NewReader(body))
+				So(Sign(req, body, "http://remote.com/alice#key", privateKey), ShouldBeNil)
+				res := httptest.NewRecorder()
+				server.ServeHTTP(res, req)
+
+				Convey("It should be rejected: "+id, func() {
+					So(res.Code, ShouldEqual, http.StatusBadRequest)
+					stream, err := server.Stream.GetStream(InboxStreamID("ACTOR_ID"), 0, 0, activitystream.After)
+					So(err, ShouldBeNil)
+					So(len(stream), ShouldEqual, 0)
+				})
+			}
+		})
+	})
+}
+
+// ************* HELPER METHODS *************
+func createTestActivity() activitystream.Activity {
+	var a activitystream.Activity
+	a.Id = bson.NewObjectId().Hex()
+	a.Published = time.Now().UTC()
+	a.Verb = "create"
+	a.Actor = activitystream.BaseObject{}
+	a.Actor.Id = "ACTOR_ID"
+	a.Actor.ObjectType = "person"
+
+	a.Object = activitystream.BaseObject{}
+	a.Object.ObjectType = "note"
+	a.Object.Id = "NOTE_ID"
+	return a
+}
+
+func removeFromRedis(ids ...string) {
+	c, err := redis.Dial(protocol, address)
+	if err != nil {
+		panic(err)
+	}
+
+	defer c.Close()
+
+	for _, id := range ids {
+		c.Send("DEL", id)
+	}
+	c.Do("")
+}
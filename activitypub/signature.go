@@ -0,0 +1,134 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// signedHeaders are the headers covered by the signatures created through Sign and required by Verify
+var signedHeaders = []string{"(request-target)", "host", "date", "digest"}
+
+// MaxSignatureAge is the maximum difference between the Date header of a signed request and the time of verification.
+const MaxSignatureAge = time.Hour
+
+var (
+	// ErrMissingSignature is returned when a request has no valid Signature header
+	ErrMissingSignature = errors.New("request is not signed")
+	// ErrUnknownKey is returned when a request is signed with a key which is not configured
+	ErrUnknownKey = errors.New("request is signed with an unknown key")
+	// ErrInvalidSignature is returned when the signature, digest or date of a request does not match
+	ErrInvalidSignature = errors.New("signature of request is invalid")
+)
+
+// PublicKey is a key used to verify signed requests of its owner
+type PublicKey struct {
+	// Owner is the ID of the actor the key belongs to
+	Owner string
+	Key   *rsa.PublicKey
+}
+
+// Sign signs the request with the private key following draft-cavage-http-signatures using rsa-sha256.
+// It sets the Date and Digest headers, body is the exact body of the request.
+func Sign(req *http.Request, body []byte, keyId string, key *rsa.PrivateKey) error {
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Digest", digest(body))
+
+	hash := sha256.Sum256([]byte(signingString(req, signedHeaders)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Signature", `keyId="`+keyId+`",algorithm="rsa-sha256",headers="`+
+		strings.Join(signedHeaders, " ")+`",signature="`+base64.StdEncoding.EncodeToString(signature)+`"`)
+	return nil
+}
+
+// Verify checks the signature of the request against the keys, which are identified by their key ID.
+// The signature must cover the headers (request-target), host, date and digest. It returns the verified key.
+func Verify(req *http.Request, body []byte, keys map[string]PublicKey) (PublicKey, error) {
+	params := parseSignature(req.Header.Get("Signature"))
+	if params["keyId"] == "" || params["signature"] == "" {
+		return PublicKey{}, ErrMissingSignature
+	}
+	key, ok := keys[params["keyId"]]
+	if !ok || key.Key == nil {
+		return PublicKey{}, ErrUnknownKey
+	}
+
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	for _, required := range signedHeaders {
+		if !contains(headers, required) {
+			return PublicKey{}, ErrInvalidSignature
+		}
+	}
+	if req.Header.Get("Digest") != digest(body) {
+		return PublicKey{}, ErrInvalidSignature
+	}
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil || time.Since(date) > MaxSignatureAge || time.Until(date) > MaxSignatureAge {
+		return PublicKey{}, ErrInvalidSignature
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return PublicKey{}, ErrInvalidSignature
+	}
+	hash := sha256.Sum256([]byte(signingString(req, headers)))
+	if err := rsa.VerifyPKCS1v15(key.Key, crypto.SHA256, hash[:], signature); err != nil {
+		return PublicKey{}, ErrInvalidSignature
+	}
+	return key, nil
+}
+
+func signingString(req *http.Request, headers []string) string {
+	lines := make([]string, len(headers))
+	for i, h := range headers {
+		switch h {
+		case "(request-target)":
+			lines[i] = h + ": " + strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			host := req.Host
+			if host == "" {
+				host = req.URL.Host
+			}
+			lines[i] = h + ": " + host
+		default:
+			lines[i] = h + ": " + req.Header.Get(h)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseSignature parses the comma separated key="value" pairs of a Signature header
+func parseSignature(header string) map[string]string {
+	params := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			continue
+		}
+		params[strings.TrimSpace(pair[:i])] = strings.Trim(strings.TrimSpace(pair[i+1:]), `"`)
+	}
+	return params
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
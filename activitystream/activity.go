@@ -1,11 +1,18 @@
 package activitystream
 
 import (
+	"encoding/json"
 	"time"
 )
 
 type ObjectType string
 
+// Object types of tags, see Activity.Tags
+const (
+	Mention ObjectType = "mention"
+	Hashtag ObjectType = "hashtag"
+)
+
 type Activity struct {
 	Id        string     `bson:"_id" json:"_id,omitempty"`
 	Published time.Time  `bson:"published" json:"published,omitempty"`
@@ -13,13 +20,49 @@ type Activity struct {
 	Actor     BaseObject `bson:"actor" json:"actor,omitempty"`
 	Object    BaseObject `bson:"object" json:"object,omitempty"`
 	Target    BaseObject `bson:"target" json:"target,omitempty"`
-	Version   string     `bson:"version" json:"version,omitempty"`
+	// optional objects, nil if not set
+	Context    *BaseObject  `bson:"context,omitempty" json:"context,omitempty"`
+	Result     *BaseObject  `bson:"result,omitempty" json:"result,omitempty"`
+	Instrument *BaseObject  `bson:"instrument,omitempty" json:"instrument,omitempty"`
+	Generator  *BaseObject  `bson:"generator,omitempty" json:"generator,omitempty"`
+	Provider   *BaseObject  `bson:"provider,omitempty" json:"provider,omitempty"`
+	Location   *BaseObject  `bson:"location,omitempty" json:"location,omitempty"`
+	Tags       []BaseObject `bson:"tags,omitempty" json:"tags,omitempty"`
+	Updated    *time.Time   `bson:"updated,omitempty" json:"updated,omitempty"`
+	Deleted    *time.Time   `bson:"deleted,omitempty" json:"deleted,omitempty"` // set on tombstones only, see Tombstone
+	To         []string     `bson:"to" json:"to,omitempty"`
+	Cc         []string     `bson:"cc" json:"cc,omitempty"`
+	Bto        []string     `bson:"bto" json:"bto,omitempty"`
+	Bcc        []string     `bson:"bcc" json:"bcc,omitempty"`
+	Version    string       `bson:"version" json:"version,omitempty"`
+	Extensions Extensions   `bson:"extensions,omitempty" json:"extensions,omitempty"`
+	Pinned     bool         `bson:"-" json:"pinned,omitempty"` // set when returned as pinned item of a stream, never stored
 }
 
+// Score returns the score of the activity given by TimeScorer, which is its time of publication in unix milliseconds.
 func (a *Activity) Score() int {
 	return int(MakeTimestamp(a.Published))
 }
 
+// Mentions returns the tags of the activity which mention an object, usually a person
+func (a *Activity) Mentions() []BaseObject {
+	var mentions []BaseObject
+	for _, tag := range a.Tags {
+		if tag.ObjectType == Mention {
+			mentions = append(mentions, tag)
+		}
+	}
+	return mentions
+}
+
+// BulkResult is the result of ActivityStream.BulkGetResults for a single ID
+type BulkResult struct {
+	Id       string
+	Activity Activity
+	// Err is ErrNotFound if there is no activity with the ID, or ErrInvalidActivity if it could not be parsed
+	Err error
+}
+
 type Actor BaseObject
 
 type Object BaseObject
@@ -34,6 +77,7 @@ type BaseObject struct {
 	DisplayName string            `bson:"displayName" json:"displayName,omitempty"`
 	Content     string            `bson:"content" json:"content,omitempty"`
 	Metadata    map[string]string `bson:"metadata" json:"metadata,omitempty"`
+	Extensions  Extensions        `bson:"extensions,omitempty" json:"extensions,omitempty"`
 }
 
 type Image struct {
@@ -41,3 +85,27 @@ type Image struct {
 	Width  int    `bson:"width" json:"width,omitempty"`
 	Height int    `bson:"height" json:"height,omitempty"`
 }
+
+// Extensions holds application specific data of an activity or object by name. Values are kept as raw JSON, so they
+// can be of any type including nested structures and are stored without loss.
+type Extensions map[string]json.RawMessage
+
+// Set stores value as JSON under the given name, the Extensions must not be nil
+func (e Extensions) Set(name string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	e[name] = data
+	return nil
+}
+
+// Get decodes the value of the given name into value, which must be a pointer.
+// It returns false if there is no such value.
+func (e Extensions) Get(name string, value interface{}) (bool, error) {
+	data, ok := e[name]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, value)
+}
@@ -0,0 +1,71 @@
+package activitystream
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+)
+
+func TestExtensions(t *testing.T) {
+	Convey("Subject: Test extension data of activities", t, func() {
+		activity := createTestActivity()
+		activity.Object.Extensions = Extensions{}
+
+		Convey("When a nested value is set", func() {
+			err := activity.Object.Extensions.Set("poll", map[string][]string{"options": {"yes", "no"}})
+			So(err, ShouldBeNil)
+
+			Convey("It should survive a JSON round trip and be decoded into its type", func() {
+				data, err := json.Marshal(activity)
+				So(err, ShouldBeNil)
+				var parsed Activity
+				So(json.Unmarshal(data, &parsed), ShouldBeNil)
+
+				var poll struct {
+					Options []string `json:"options"`
+				}
+				ok, err := parsed.Object.Extensions.Get("poll", &poll)
+				So(ok, ShouldBeTrue)
+				So(err, ShouldBeNil)
+				So(poll.Options, ShouldResemble, []string{"yes", "no"})
+			})
+		})
+		Convey("When a missing value is requested", func() {
+			var value string
+			ok, err := activity.Extensions.Get("missing", &value)
+
+			Convey("It should report it as not found", func() {
+				So(ok, ShouldBeFalse)
+				So(err, ShouldBeNil)
+			})
+		})
+		Convey("When an activity has no optional objects", func() {
+			data, err := json.Marshal(activity)
+			So(err, ShouldBeNil)
+
+			Convey("It should omit them in JSON", func() {
+				var raw map[string]interface{}
+				So(json.Unmarshal(data, &raw), ShouldBeNil)
+				So(raw, ShouldNotContainKey, "context")
+				So(raw, ShouldNotContainKey, "updated")
+				So(raw, ShouldNotContainKey, "tags")
+			})
+		})
+	})
+}
+
+func TestMentions(t *testing.T) {
+	Convey("Subject: Test mentions of activities", t, func() {
+		activity := createTestActivity()
+		activity.Tags = []BaseObject{
+			{DisplayName: "#go", ObjectType: Hashtag},
+			{Id: "FRIEND_ID", ObjectType: Mention},
+		}
+
+		Convey("When the mentions are requested", func() {
+			Convey("It should only return tags of type mention", func() {
+				So(activity.Mentions(), ShouldResemble, []BaseObject{{Id: "FRIEND_ID", ObjectType: Mention}})
+			})
+		})
+	})
+}
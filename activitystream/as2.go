@@ -0,0 +1,376 @@
+package activitystream
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// AS2Context is the JSON-LD context of Activity Streams 2.0
+const AS2Context = "https://www.w3.org/ns/activitystreams"
+
+// AS2ExtensionContext defines the terms of Activity and BaseObject which have no equivalent in Activity Streams 2.0
+var AS2ExtensionContext = map[string]string{
+	"version":    "https://github.com/chrisport/go-activitystream#version",
+	"metadata":   "https://github.com/chrisport/go-activitystream#metadata",
+	"provider":   "https://github.com/chrisport/go-activitystream#provider",
+	"extensions": "https://github.com/chrisport/go-activitystream#extensions",
+}
+
+// as2Vocabulary contains the activity and object types of Activity Streams 2.0 which share their name with the
+// lowercase verbs and object types of activitystrea.ms 1.0.
+var as2Vocabulary = []string{
+	"Accept", "Add", "Announce", "Arrive", "Block", "Create", "Delete", "Dislike", "Flag", "Follow", "Ignore", "Invite",
+	"Join", "Leave", "Like", "Listen", "Move", "Offer", "Question", "Reject", "Read", "Remove", "Travel", "Undo",
+	"Update", "View",
+	"Application", "Article", "Audio", "Collection", "Document", "Event", "Group", "Image", "Note", "Organization",
+	"Page", "Person", "Place", "Profile", "Relationship", "Service", "Tombstone", "Video",
+	"Mention", "Hashtag",
+}
+
+// Names of the extensions which keep verbs and object types that would otherwise change on their way to Activity
+// Streams 2.0 and back, e.g. the verb "Follow" which would come back as "follow"
+const (
+	as2VerbExtension       = "as1:verb"
+	as2ObjectTypeExtension = "as1:objectType"
+)
+
+// AS2Activity is the Activity Streams 2.0 representation of an Activity.
+// It can be converted from and to an Activity without loss, see ToAS2 and ToActivity.
+type AS2Activity struct {
+	Context   interface{} `json:"@context,omitempty"`
+	Type      string      `json:"type,omitempty"`
+	Id        string      `json:"id,omitempty"`
+	Published *time.Time  `json:"published,omitempty"`
+	Updated   *time.Time  `json:"updated,omitempty"`
+	// Deleted and FormerType are set on tombstones only, see Activity.Tombstone
+	Deleted    *time.Time `json:"deleted,omitempty"`
+	FormerType string     `json:"formerType,omitempty"`
+	Actor      *AS2Object `json:"actor,omitempty"`
+	Object     *AS2Object `json:"object,omitempty"`
+	Target     *AS2Object `json:"target,omitempty"`
+	Result     *AS2Object `json:"result,omitempty"`
+	Instrument *AS2Object `json:"instrument,omitempty"`
+	Generator  *AS2Object `json:"generator,omitempty"`
+	Provider   *AS2Object `json:"provider,omitempty"`
+	Location   *AS2Object `json:"location,omitempty"`
+	// ActivityContext is the context property of Activity Streams 2.0, not to be confused with the JSON-LD @context
+	ActivityContext *AS2Object  `json:"context,omitempty"`
+	Tag             []AS2Object `json:"tag,omitempty"`
+	To              AS2Audience `json:"to,omitempty"`
+	Cc              AS2Audience `json:"cc,omitempty"`
+	Bto             AS2Audience `json:"bto,omitempty"`
+	Bcc             AS2Audience `json:"bcc,omitempty"`
+	Version         string      `json:"version,omitempty"`
+	Extensions      Extensions  `json:"extensions,omitempty"`
+}
+
+// AS2Audience are the IDs of the recipients of an activity, like to and cc.
+// When parsed it accepts a single recipient in place of an array, and objects or links in place of IRIs.
+type AS2Audience []string
+
+// AS2Object is the Activity Streams 2.0 representation of a BaseObject.
+// When parsed it accepts a plain IRI in place of the object, which becomes its Id, and an array of which the first
+// object is taken.
+type AS2Object struct {
+	Type     string            `json:"type,omitempty"`
+	Id       string            `json:"id,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	Content  string            `json:"content,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Image    *AS2Image         `json:"image,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Extensions of the object, also used by the activity
+	Extensions Extensions `json:"extensions,omitempty"`
+}
+
+// AS2Image is the Activity Streams 2.0 representation of an Image.
+type AS2Image struct {
+	Type   string `json:"type,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// ToAS2 converts the activity to Activity Streams 2.0. Lowercase verbs and object types of the 1.0 vocabulary are
+// converted to their Activity Streams 2.0 type, e.g. "follow" becomes "Follow". All other values are kept as they are,
+// those which are already named like the type, e.g. "Follow", are also kept in the extensions to be restored by
+// ToActivity. A tombstone becomes a Tombstone with the verb as formerType.
+func (a *Activity) ToAS2() AS2Activity {
+	as2 := AS2Activity{
+		Context:         []interface{}{AS2Context, AS2ExtensionContext},
+		Type:            toAS2Type(a.Verb),
+		Id:              a.Id,
+		Updated:         a.Updated,
+		Actor:           toAS2Object(a.Actor),
+		Object:          toAS2Object(a.Object),
+		Target:          toAS2Object(a.Target),
+		Result:          toAS2ObjectPtr(a.Result),
+		Instrument:      toAS2ObjectPtr(a.Instrument),
+		Generator:       toAS2ObjectPtr(a.Generator),
+		Provider:        toAS2ObjectPtr(a.Provider),
+		Location:        toAS2ObjectPtr(a.Location),
+		ActivityContext: toAS2ObjectPtr(a.Context),
+		To:              AS2Audience(a.To),
+		Cc:              AS2Audience(a.Cc),
+		Bto:             AS2Audience(a.Bto),
+		Bcc:             AS2Audience(a.Bcc),
+		Version:         a.Version,
+		Extensions:      withOriginalTerm(a.Extensions, as2VerbExtension, a.Verb),
+	}
+	if !a.Published.IsZero() {
+		published := a.Published
+		as2.Published = &published
+	}
+	for i := range a.Tags {
+		as2.Tag = append(as2.Tag, *toAS2ObjectPtr(&a.Tags[i]))
+	}
+	if a.IsTombstone() {
+		as2.Type = "Tombstone"
+		as2.FormerType = toAS2Type(a.Verb)
+		as2.Deleted = a.Deleted
+	}
+	return as2
+}
+
+// ToActivity converts the Activity Streams 2.0 activity back to an Activity, it reverts ToAS2.
+// Types of the Activity Streams 2.0 vocabulary are converted to the lowercase 1.0 verbs and object types, unless the
+// extensions keep the original verb or object type.
+func (as2 *AS2Activity) ToActivity() Activity {
+	a := Activity{
+		Id:         as2.Id,
+		Updated:    as2.Updated,
+		Actor:      fromAS2Object(as2.Actor),
+		Object:     fromAS2Object(as2.Object),
+		Target:     fromAS2Object(as2.Target),
+		Result:     fromAS2ObjectPtr(as2.Result),
+		Instrument: fromAS2ObjectPtr(as2.Instrument),
+		Generator:  fromAS2ObjectPtr(as2.Generator),
+		Provider:   fromAS2ObjectPtr(as2.Provider),
+		Location:   fromAS2ObjectPtr(as2.Location),
+		Context:    fromAS2ObjectPtr(as2.ActivityContext),
+		To:         []string(as2.To),
+		Cc:         []string(as2.Cc),
+		Bto:        []string(as2.Bto),
+		Bcc:        []string(as2.Bcc),
+		Version:    as2.Version,
+	}
+	a.Verb, a.Extensions = restoreTerm(as2.Extensions, as2VerbExtension, as2.Type)
+	if as2.Published != nil {
+		a.Published = *as2.Published
+	}
+	for i := range as2.Tag {
+		a.Tags = append(a.Tags, fromAS2Object(&as2.Tag[i]))
+	}
+	if as2.Type == "Tombstone" && as2.Deleted != nil {
+		a.Verb, a.Extensions = restoreTerm(as2.Extensions, as2VerbExtension, as2.FormerType)
+		a.Deleted = as2.Deleted
+	}
+	return a
+}
+
+// MarshalAS2 returns the activity as Activity Streams 2.0 JSON-LD
+func (a *Activity) MarshalAS2() ([]byte, error) {
+	return json.Marshal(a.ToAS2())
+}
+
+// UnmarshalAS2 parses Activity Streams 2.0 JSON-LD into the activity
+func (a *Activity) UnmarshalAS2(data []byte) error {
+	var as2 AS2Activity
+	if err := json.Unmarshal(data, &as2); err != nil {
+		return err
+	}
+	*a = as2.ToActivity()
+	return nil
+}
+
+// UnmarshalJSON accepts a single recipient as well as an array. Recipients given as objects or links are taken by
+// their id or href.
+func (audience *AS2Audience) UnmarshalJSON(data []byte) error {
+	var values []json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		values = []json.RawMessage{data}
+	}
+	*audience = nil
+	for _, value := range values {
+		var recipient AS2Object
+		if err := json.Unmarshal(value, &recipient); err != nil {
+			return err
+		}
+		if recipient.Id == "" {
+			recipient.Id = recipient.URL
+		}
+		if recipient.Id != "" {
+			*audience = append(*audience, recipient.Id)
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts an object as well as a plain IRI, of an array the first object is taken. Properties which may
+// be arrays in Activity Streams 2.0, like type and url, are reduced to their first value. The href of a link is taken
+// as url.
+func (o *AS2Object) UnmarshalJSON(data []byte) error {
+	var iri string
+	if err := json.Unmarshal(data, &iri); err == nil {
+		*o = AS2Object{Id: iri}
+		return nil
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal(data, &values); err == nil {
+		*o = AS2Object{}
+		if len(values) == 0 {
+			return nil
+		}
+		return o.UnmarshalJSON(values[0])
+	}
+
+	type plainObject AS2Object
+	var raw struct {
+		plainObject
+		Type json.RawMessage `json:"type,omitempty"`
+		URL  json.RawMessage `json:"url,omitempty"`
+		Href string          `json:"href,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*o = AS2Object(raw.plainObject)
+	o.Type = firstString(raw.Type, "")
+	o.URL = firstString(raw.URL, "href")
+	if o.URL == "" {
+		// links like Mention carry their address as href
+		o.URL = raw.Href
+	}
+	return nil
+}
+
+// firstString returns the value of a JSON string, the first string of an array or the value of the property key of
+// an object.
+func firstString(data json.RawMessage, key string) string {
+	if len(data) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return s
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal(data, &values); err == nil {
+		if len(values) == 0 {
+			return ""
+		}
+		return firstString(values[0], key)
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err == nil && key != "" {
+		return firstString(object[key], "")
+	}
+	return ""
+}
+
+func toAS2Object(o BaseObject) *AS2Object {
+	if o.Id == "" && o.URL == "" && o.ObjectType == "" && o.DisplayName == "" && o.Content == "" &&
+		o.Image == (Image{}) && len(o.Metadata) == 0 && len(o.Extensions) == 0 {
+		return nil
+	}
+	return toAS2ObjectPtr(&o)
+}
+
+// toAS2ObjectPtr converts an optional object, other than toAS2Object it keeps objects which are set but empty
+func toAS2ObjectPtr(o *BaseObject) *AS2Object {
+	if o == nil {
+		return nil
+	}
+	as2 := &AS2Object{
+		Type:       toAS2Type(string(o.ObjectType)),
+		Id:         o.Id,
+		Name:       o.DisplayName,
+		Content:    o.Content,
+		URL:        o.URL,
+		Metadata:   o.Metadata,
+		Extensions: withOriginalTerm(o.Extensions, as2ObjectTypeExtension, string(o.ObjectType)),
+	}
+	if o.Image != (Image{}) {
+		as2.Image = &AS2Image{Type: "Image", URL: o.Image.URL, Width: o.Image.Width, Height: o.Image.Height}
+	}
+	return as2
+}
+
+func fromAS2Object(as2 *AS2Object) BaseObject {
+	if as2 == nil {
+		return BaseObject{}
+	}
+	o := BaseObject{
+		Id:          as2.Id,
+		URL:         as2.URL,
+		DisplayName: as2.Name,
+		Content:     as2.Content,
+		Metadata:    as2.Metadata,
+	}
+	objectType, extensions := restoreTerm(as2.Extensions, as2ObjectTypeExtension, as2.Type)
+	o.ObjectType, o.Extensions = ObjectType(objectType), extensions
+	if as2.Image != nil {
+		o.Image = Image{URL: as2.Image.URL, Width: as2.Image.Width, Height: as2.Image.Height}
+	}
+	return o
+}
+
+func fromAS2ObjectPtr(as2 *AS2Object) *BaseObject {
+	if as2 == nil {
+		return nil
+	}
+	o := fromAS2Object(as2)
+	return &o
+}
+
+func toAS2Type(name string) string {
+	for _, t := range as2Vocabulary {
+		if name == strings.ToLower(t) {
+			return t
+		}
+	}
+	return name
+}
+
+func fromAS2Type(t string) string {
+	for _, v := range as2Vocabulary {
+		if t == v {
+			return strings.ToLower(t)
+		}
+	}
+	return t
+}
+
+// withOriginalTerm returns the extensions along with the verb or object type under name, if the term would not be
+// restored by fromAS2Type, e.g. "Follow". The given extensions are not modified.
+func withOriginalTerm(e Extensions, name, term string) Extensions {
+	if fromAS2Type(toAS2Type(term)) == term {
+		return e
+	}
+	extended := make(Extensions, len(e)+1)
+	for k, v := range e {
+		extended[k] = v
+	}
+	extended.Set(name, term)
+	return extended
+}
+
+// restoreTerm returns the verb or object type of the AS2 type t, the original term if it is kept under name by
+// withOriginalTerm, and the extensions without it
+func restoreTerm(e Extensions, name, t string) (string, Extensions) {
+	var term string
+	if raw, ok := e[name]; !ok || json.Unmarshal(raw, &term) != nil || toAS2Type(term) != t {
+		return fromAS2Type(t), e
+	}
+	var restored Extensions
+	for k, v := range e {
+		if k != name {
+			if restored == nil {
+				restored = make(Extensions, len(e)-1)
+			}
+			restored[k] = v
+		}
+	}
+	return term, restored
+}
@@ -0,0 +1,171 @@
+package activitystream
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"reflect"
+	testing "testing"
+	"time"
+)
+
+func TestAS2Conversion(t *testing.T) {
+	activity := createTestActivity()
+	activity.Verb = "follow"
+	activity.Version = "3"
+	activity.Actor.ObjectType = "person"
+	activity.Actor.DisplayName = "Some Person"
+	activity.Actor.Image = Image{URL: "http://example.com/avatar.png", Width: 20, Height: 20}
+	activity.Object.Content = "Hello"
+	activity.Object.URL = "http://example.com/community"
+	activity.Object.Metadata = map[string]string{"members": "10"}
+	activity.To = []string{PublicAudience}
+	activity.Bcc = []string{"SECRET_FRIEND_ID"}
+	updated := activity.Published.Add(time.Hour)
+	activity.Updated = &updated
+	activity.Context = &BaseObject{Id: "CONVERSATION_ID"}
+	activity.Provider = &BaseObject{Id: "http://example.com", ObjectType: "service"}
+	activity.Tags = []BaseObject{{URL: "http://example.com/users/friend", DisplayName: "@friend", ObjectType: Mention}}
+	activity.Extensions = Extensions{"rating": json.RawMessage(`{"stars":4}`)}
+
+	Convey("Subject: Test conversion to Activity Streams 2.0", t, func() {
+		Convey("When an activity is converted", func() {
+			as2 := activity.ToAS2()
+
+			Convey("It should map the 1.0 fields to their AS2 equivalent", func() {
+				So(as2.Id, ShouldEqual, activity.Id)
+				So(as2.Type, ShouldEqual, "Follow")
+				So(as2.Actor.Type, ShouldEqual, "Person")
+				So(as2.Actor.Name, ShouldEqual, "Some Person")
+				So(as2.Actor.Image.URL, ShouldEqual, "http://example.com/avatar.png")
+				So(as2.Object.Type, ShouldEqual, "Community")
+				So(as2.Target, ShouldBeNil)
+				So(as2.To, ShouldResemble, AS2Audience{PublicAudience})
+				So(as2.Bcc, ShouldResemble, AS2Audience{"SECRET_FRIEND_ID"})
+				So(as2.ActivityContext.Id, ShouldEqual, "CONVERSATION_ID")
+				So(as2.Provider.Type, ShouldEqual, "Service")
+				So(as2.Tag[0].Type, ShouldEqual, "Mention")
+			})
+			Convey("It should convert back without loss", func() {
+				So(reflect.DeepEqual(as2.ToActivity(), activity), ShouldBeTrue)
+			})
+			Convey("It should be marshalled as JSON-LD and unmarshalled without loss", func() {
+				data, err := activity.MarshalAS2()
+				So(err, ShouldBeNil)
+
+				var raw map[string]interface{}
+				So(json.Unmarshal(data, &raw), ShouldBeNil)
+				So(raw["@context"], ShouldNotBeNil)
+				So(raw["type"], ShouldEqual, "Follow")
+				So(raw["id"], ShouldEqual, activity.Id)
+
+				var parsed Activity
+				So(parsed.UnmarshalAS2(data), ShouldBeNil)
+				So(parsed.Published.Equal(activity.Published), ShouldBeTrue)
+				So(parsed.Updated.Equal(*activity.Updated), ShouldBeTrue)
+				parsed.Published = activity.Published
+				parsed.Updated = activity.Updated
+				So(reflect.DeepEqual(parsed, activity), ShouldBeTrue)
+			})
+		})
+
+		Convey("When a tombstone is converted", func() {
+			tombstone := activity.Tombstone(activity.Published.Add(time.Minute))
+			as2 := tombstone.ToAS2()
+
+			Convey("It should become a Tombstone with the verb as former type", func() {
+				So(as2.Type, ShouldEqual, "Tombstone")
+				So(as2.FormerType, ShouldEqual, "Follow")
+				So(as2.Object, ShouldBeNil)
+				converted := as2.ToActivity()
+				So(converted.Verb, ShouldEqual, "follow")
+				So(converted.IsTombstone(), ShouldBeTrue)
+			})
+		})
+
+		Convey("When verbs and object types are already named like AS2 types", func() {
+			mixed := createTestActivity()
+			mixed.Verb = "Follow"
+			mixed.Actor.ObjectType = "Profile"
+			mixed.Object.ObjectType = "note"
+			mixed.Target.ObjectType = "Person"
+			mixed.Target.Extensions = Extensions{"rating": json.RawMessage(`4`)}
+			as2 := mixed.ToAS2()
+
+			Convey("It should convert them to the same AS2 types", func() {
+				So(as2.Type, ShouldEqual, "Follow")
+				So(as2.Actor.Type, ShouldEqual, "Profile")
+				So(as2.Object.Type, ShouldEqual, "Note")
+				So(mixed.Extensions, ShouldBeNil)
+				So(len(mixed.Target.Extensions), ShouldEqual, 1)
+			})
+			Convey("It should convert back without loss", func() {
+				So(reflect.DeepEqual(as2.ToActivity(), mixed), ShouldBeTrue)
+				data, err := mixed.MarshalAS2()
+				So(err, ShouldBeNil)
+				var parsed Activity
+				So(parsed.UnmarshalAS2(data), ShouldBeNil)
+				So(parsed.Verb, ShouldEqual, "Follow")
+				So(parsed.Actor.ObjectType, ShouldEqual, "Profile")
+				So(parsed.Object.ObjectType, ShouldEqual, "note")
+				So(parsed.Target.ObjectType, ShouldEqual, "Person")
+				So(parsed.Target.Extensions, ShouldResemble, mixed.Target.Extensions)
+			})
+			Convey("It should keep the verb of a tombstone", func() {
+				tombstone := mixed.Tombstone(mixed.Published.Add(time.Minute))
+				tombstoneAS2 := tombstone.ToAS2()
+				So(tombstoneAS2.ToActivity().Verb, ShouldEqual, "Follow")
+			})
+		})
+
+		Convey("When AS2 JSON with IRIs and arrays is unmarshalled", func() {
+			data := []byte(`{
+				"@context": "https://www.w3.org/ns/activitystreams",
+				"type": "Create",
+				"id": "http://example.com/activities/1",
+				"actor": "http://example.com/users/alice",
+				"object": {"type": ["Note"], "id": "http://example.com/notes/1", "url": [{"type": "Link", "href": "http://example.com/n/1"}]},
+				"to": ["https://www.w3.org/ns/activitystreams#Public"],
+				"tag": [{"type": "Mention", "href": "http://example.com/users/bob", "name": "@bob"}]
+			}`)
+			var parsed Activity
+			err := parsed.UnmarshalAS2(data)
+
+			Convey("It should resolve them to the 1.0 fields", func() {
+				So(err, ShouldBeNil)
+				So(parsed.Id, ShouldEqual, "http://example.com/activities/1")
+				So(parsed.Verb, ShouldEqual, "create")
+				So(parsed.Actor.Id, ShouldEqual, "http://example.com/users/alice")
+				So(parsed.Object.ObjectType, ShouldEqual, "note")
+				So(parsed.Object.URL, ShouldEqual, "http://example.com/n/1")
+				So(parsed.To, ShouldResemble, []string{PublicAudience})
+				So(parsed.Mentions()[0].URL, ShouldEqual, "http://example.com/users/bob")
+			})
+		})
+		Convey("When AS2 JSON with single recipients and arrays of actors is unmarshalled", func() {
+			data := []byte(`{
+				"type": "Announce",
+				"actor": [{"type": "Person", "id": "http://example.com/users/alice"}, "http://example.com/users/bob"],
+				"object": ["http://example.com/notes/1"],
+				"to": "https://www.w3.org/ns/activitystreams#Public",
+				"cc": {"type": "Collection", "id": "http://example.com/users/alice/followers"},
+				"bcc": ["http://example.com/users/carol", {"type": "Link", "href": "http://example.com/users/dave"}]
+			}`)
+			var parsed Activity
+			err := parsed.UnmarshalAS2(data)
+
+			Convey("It should take the first actor and object", func() {
+				So(err, ShouldBeNil)
+				So(parsed.Actor.Id, ShouldEqual, "http://example.com/users/alice")
+				So(parsed.Actor.ObjectType, ShouldEqual, "person")
+				So(parsed.Object.Id, ShouldEqual, "http://example.com/notes/1")
+			})
+			Convey("It should resolve single recipients as well as arrays to the audience", func() {
+				So(err, ShouldBeNil)
+				So(parsed.To, ShouldResemble, []string{PublicAudience})
+				So(parsed.Cc, ShouldResemble, []string{"http://example.com/users/alice/followers"})
+				So(parsed.Bcc, ShouldResemble, []string{"http://example.com/users/carol", "http://example.com/users/dave"})
+				So(parsed.Bto, ShouldBeNil)
+			})
+		})
+	})
+}
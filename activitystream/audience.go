@@ -0,0 +1,44 @@
+package activitystream
+
+// PublicAudience is the special collection of Activity Streams 2.0 addressing everyone
+const PublicAudience = "https://www.w3.org/ns/activitystreams#Public"
+
+// publicAudiences are the notations of PublicAudience which are allowed by Activity Streams 2.0
+var publicAudiences = []string{PublicAudience, "as:Public", "Public"}
+
+// Audience returns all IDs the activity is addressed to through To, Cc, Bto and Bcc
+func (a *Activity) Audience() []string {
+	audience := make([]string, 0, len(a.To)+len(a.Cc)+len(a.Bto)+len(a.Bcc))
+	audience = append(audience, a.To...)
+	audience = append(audience, a.Cc...)
+	audience = append(audience, a.Bto...)
+	return append(audience, a.Bcc...)
+}
+
+// IsVisibleTo reports whether the viewer may see the activity. This is the case if
+// 		the activity has no audience at all, which makes it public
+// 		the viewer is the actor of the activity
+// 		the activity is addressed to the viewer, to PublicAudience or to a collection the viewer is member of
+// isMember reports whether the viewer is member of a collection, it may be nil if there are no collections.
+// An empty viewer ID stands for an anonymous viewer, who only sees public activities.
+func (a *Activity) IsVisibleTo(viewerId string, isMember func(collectionId string) bool) bool {
+	audience := a.Audience()
+	if len(audience) == 0 || (viewerId != "" && a.Actor.Id == viewerId) {
+		return true
+	}
+	for _, id := range audience {
+		if (viewerId != "" && id == viewerId) || isPublic(id) || (isMember != nil && isMember(id)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPublic(id string) bool {
+	for _, public := range publicAudiences {
+		if id == public {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package activitystream
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+)
+
+func TestIsVisibleTo(t *testing.T) {
+	followers := func(collectionId string) bool {
+		return collectionId == "ACTOR_ID-followers"
+	}
+
+	Convey("Subject: Test visibility of activities by their audience", t, func() {
+		activity := createTestActivity()
+
+		Convey("When an activity has no audience", func() {
+			Convey("It should be visible to everyone", func() {
+				So(activity.IsVisibleTo("VIEWER_ID", nil), ShouldBeTrue)
+				So(activity.IsVisibleTo("", nil), ShouldBeTrue)
+			})
+		})
+		Convey("When an activity is addressed to the public", func() {
+			activity.Cc = []string{"as:Public"}
+
+			Convey("It should be visible to everyone", func() {
+				So(activity.IsVisibleTo("VIEWER_ID", nil), ShouldBeTrue)
+				So(activity.IsVisibleTo("", nil), ShouldBeTrue)
+			})
+		})
+		Convey("When an activity is addressed to certain viewers", func() {
+			activity.To = []string{"FRIEND_ID"}
+			activity.Bcc = []string{"SECRET_FRIEND_ID"}
+
+			Convey("It should be visible to these viewers and the actor only", func() {
+				So(activity.IsVisibleTo("FRIEND_ID", nil), ShouldBeTrue)
+				So(activity.IsVisibleTo("SECRET_FRIEND_ID", nil), ShouldBeTrue)
+				So(activity.IsVisibleTo(activity.Actor.Id, nil), ShouldBeTrue)
+				So(activity.IsVisibleTo("VIEWER_ID", nil), ShouldBeFalse)
+				So(activity.IsVisibleTo("", nil), ShouldBeFalse)
+			})
+		})
+		Convey("When an activity is addressed to a collection", func() {
+			activity.To = []string{"ACTOR_ID-followers"}
+
+			Convey("It should be visible to its members only", func() {
+				So(activity.IsVisibleTo("FOLLOWER_ID", followers), ShouldBeTrue)
+				So(activity.IsVisibleTo("FOLLOWER_ID", nil), ShouldBeFalse)
+			})
+		})
+	})
+}
@@ -0,0 +1,53 @@
+package activitystream
+
+import (
+	"errors"
+)
+
+// Errors returned by implementations of ActivityStream, independent of their backend. Implementations may wrap them
+// along with the cause, use errors.Is to check for them.
+var (
+	// ErrNotFound is returned if an activity or stream does not exist
+	ErrNotFound = errors.New("activitystream: not found")
+	// ErrInvalidActivity is returned if an activity is rejected by validation or could not be encoded or decoded
+	ErrInvalidActivity = errors.New("activitystream: invalid activity")
+	// ErrStreamFull is returned if an activity was not kept in a stream because the stream has reached its maximum size
+	// and all its activities have a higher score
+	ErrStreamFull = errors.New("activitystream: stream full")
+	// ErrExpired is returned if an activity was not kept in a stream because it is older than the MaxAge of the
+	// RetentionPolicy of the stream
+	ErrExpired = errors.New("activitystream: expired")
+	// ErrVersionConflict is returned if an activity is updated, but has been changed since the expected version
+	ErrVersionConflict = errors.New("activitystream: version conflict")
+	// ErrBackendUnavailable is returned if the backend could not be reached
+	ErrBackendUnavailable = errors.New("activitystream: backend unavailable")
+)
+
+// ErrEmpty is returned if an activity does not exist.
+// Deprecated: use ErrNotFound
+var ErrEmpty = ErrNotFound
+
+// Error wraps the cause of a failure with one of the backend independent errors
+type Error struct {
+	Kind  error
+	Cause error
+}
+
+// Wrap returns the cause wrapped with kind, so that both errors.Is(err, kind) and errors.Is(err, cause) hold
+func Wrap(kind, cause error) error {
+	return &Error{Kind: kind, Cause: cause}
+}
+
+func (e *Error) Error() string {
+	return e.Kind.Error() + ": " + e.Cause.Error()
+}
+
+// Unwrap returns the cause
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Is reports whether target is the kind of the error
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
@@ -0,0 +1,36 @@
+package activitystream
+
+import (
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+)
+
+func TestErrors(t *testing.T) {
+	Convey("Subject: Test wrapping of backend errors", t, func() {
+		cause := errors.New("connection refused")
+
+		Convey("When a cause is wrapped", func() {
+			err := Wrap(ErrBackendUnavailable, cause)
+
+			Convey("It should match the kind as well as the cause", func() {
+				So(errors.Is(err, ErrBackendUnavailable), ShouldBeTrue)
+				So(errors.Is(err, cause), ShouldBeTrue)
+				So(errors.Is(err, ErrNotFound), ShouldBeFalse)
+				So(err.Error(), ShouldEqual, "activitystream: backend unavailable: connection refused")
+			})
+		})
+		Convey("When an activity fails validation", func() {
+			err := (&Activity{}).Validate()
+
+			Convey("It should match ErrInvalidActivity", func() {
+				So(errors.Is(err, ErrInvalidActivity), ShouldBeTrue)
+			})
+		})
+		Convey("When ErrEmpty is compared", func() {
+			Convey("It should be ErrNotFound", func() {
+				So(ErrEmpty, ShouldEqual, ErrNotFound)
+			})
+		})
+	})
+}
@@ -9,13 +9,17 @@
 package activitystream
 
 import (
-	"github.com/garyburd/redigo/redis"
+	"time"
 )
 
 // DefaultMaxStreamSize is the number of elements a stream can store by default.
 // This number can be adjusted on the ActivityStream using its method SetMaxStreamSize.
 const DefaultMaxStreamSize = 50
 
+// DefaultMaxHistorySize is the number of prior revisions kept for every activity by default.
+// This number can be adjusted on the ActivityStream using its method SetMaxHistorySize.
+const DefaultMaxHistorySize = 20
+
 // Direction represents the direction a pagination token is going
 type Direction bool
 
@@ -26,8 +30,6 @@ const (
 	Before Direction = false
 )
 
-var ErrEmpty = redis.ErrNil
-
 // ActivityStream interface defines functionality to implement an activity stream. An activity can be stored and added
 // to a stream. A stream is always sorted with the newest (last insterted) element on top.
 type ActivityStream interface {
@@ -36,30 +38,124 @@ type ActivityStream interface {
 
 	// SetMaxStreamSize will set the maximum number of elements of a stream to the specified number.
 	// A negative number means there is no limit, the streams will keep growing.
+	// The maximum size of single streams can be set through RetentionPolicy.MaxSize, see SetRetentionPolicies.
 	// Important: Decreasing this number will
-	// 		1. not affect existing streams unless a new element is added.
+	// 		1. not affect existing streams unless a new element is added or they are trimmed through Trim.
 	// 		2. by adding a new element to an existing stream, the stream will be cut down to the new maximum
 	SetMaxStreamSize(maxStreamSize int)
 
+	// SetRetentionPolicies sets the policies which define how long activities are kept in streams and in the database.
+	// The first policy matching a stream applies to it.
+	SetRetentionPolicies(policies ...RetentionPolicy)
+
+	// Trim applies the maximum size and age to the stream right away, which is otherwise done when an activity is
+	// added. It returns the number of removed activities.
+	Trim(streamId string) (int, error)
+
+	// SetScorer sets the Scorer used to sort activities within their streams, nil restores the default TimeScorer.
+	// Important: Changing the Scorer will not affect existing streams unless they are rescored through Rescore.
+	SetScorer(scorer Scorer)
+
+	// Rescore recomputes the scores of all activities in a stream with the current Scorer.
+	Rescore(streamId string) error
+
+	// SetValidator sets the rules activities must follow to be stored by Store and AddToStreams, nil disables
+	// validation, which is the default.
+	SetValidator(validator Validator)
+
+	// SetResolver sets the Resolver used to replace actor, object and target of activities by their current version
+	// when they are read, nil disables hydration, which is the default. See Hydrate.
+	SetResolver(resolver Resolver)
+
 	// Get returns a single Activity by its ID
 	Get(id string) (activity Activity, err error)
 
-	// BulkGet returns an array of Activity by their IDs
+	// BulkGet returns an array of Activity by their IDs. Activities which are missing or invalid are left out, use
+	// BulkGetResults to find out which.
 	BulkGet(id ...string) ([]Activity, error)
 
+	// BulkGetResults returns the activities of the IDs, the result at a position belongs to the ID at the same
+	// position and contains either the activity or the error retrieving it. The error is only returned if no activity
+	// could be retrieved at all, e.g. because the backend is unavailable.
+	BulkGetResults(ids ...string) ([]BulkResult, error)
+
 	// Store stores a single Activity in the database
 	// This method is idempotent since the Activity is identified by its ID.
 	Store(activity Activity) error
 
-	// GetStream returns an array of Activity belonging to a certain stream. First element is last inserted.
+	// Update replaces the stored activity with the same ID if its Version equals expectedVersion, otherwise
+	// ErrVersionConflict is returned. An empty version stands for an activity which has never been updated.
+	// The replaced revision is kept and returned by History.
+	Update(activity Activity, expectedVersion string) error
+
+	// History returns the prior revisions of the activity replaced by Update, the newest first
+	History(id string) ([]Activity, error)
+
+	// SetMaxHistorySize sets the number of prior revisions kept for every activity.
+	// Zero restores DefaultMaxHistorySize, a negative number keeps all revisions.
+	SetMaxHistorySize(maxHistorySize int)
+
+	// Delete replaces the activity by its tombstone, which is returned by GetStream in place of the activity until it
+	// expires. Afterwards the activity is left out of its streams.
+	Delete(id string) error
+
+	// SetTombstoneTTL sets the time a tombstone replaces a deleted activity.
+	// Zero restores DefaultTombstoneTTL, a negative duration keeps tombstones forever.
+	SetTombstoneTTL(ttl time.Duration)
+
+	// GetStream returns an array of Activity belonging to a certain stream. First element has the highest score, by
+	// default this is the last published.
 	// The stream is identified by its ID.
 	// Pagination is provided as follow:
 	//	limit		the size of the page
-	//	pivotID		the last received ID, this element will not be included in the result
+	//	pivotID		the score of the last received element, this element will not be included in the result
 	//	direction	the direction from pivotID, the page starts either After the pivot or Before the pivot
 	GetStream(streamId string, limit int, pivotID int, direction Direction) ([]Activity, error)
 
+	// GetStreamForViewer returns the same page as GetStream, but leaves out activities which are not visible to the viewer:
+	//	activities of actors the viewer has muted or blocked and of actors who have blocked the viewer
+	//	activities with an audience which does not address the viewer, see Activity.IsVisibleTo
+	// Left out activities do not reduce the page size. An empty viewerId stands for an anonymous viewer.
+	GetStreamForViewer(streamId, viewerId string, limit int, pivotID int, direction Direction) ([]Activity, error)
+
 	// AddToStreams adds a certain activity to one or more streams. The streams are identified by their IDs
 	// Important: This will also write the activity to database, a call to the method 'Store' would be duplicate
 	AddToStreams(activity Activity, streamIds ...string) []error
+
+	// Pin pins the activity to the top of the stream. Pinned activities are returned first on the initial page of
+	// GetStream, the last pinned on top, and are left out of all other pages.
+	Pin(streamId, activityId string) error
+
+	// Unpin reverts Pin, the activity will appear at its regular position in the stream again.
+	Unpin(streamId, activityId string) error
+
+	// AddToCollection adds members to a collection, e.g. the followers of an actor. Activities addressed to the
+	// collection are visible to its members when reading through GetStreamForViewer.
+	AddToCollection(collectionId string, memberIds ...string) error
+
+	// RemoveFromCollection removes members from a collection.
+	RemoveFromCollection(collectionId string, memberIds ...string) error
+
+	// Mute hides all activities of the actor from the viewer when reading through GetStreamForViewer.
+	Mute(viewerId, actorId string) error
+
+	// Unmute reverts Mute, the activities of the actor will be visible to the viewer again.
+	Unmute(viewerId, actorId string) error
+
+	// Block hides all activities of the actor from the viewer and all activities of the viewer from the actor when
+	// reading through GetStreamForViewer.
+	Block(viewerId, actorId string) error
+
+	// Unblock reverts Block, viewer and actor will see each others activities again.
+	Unblock(viewerId, actorId string) error
+
+	// ForTenant returns an ActivityStream whose activities, streams and all other data are isolated from those of
+	// other tenants and capped by the TenantLimits of the tenant.
+	ForTenant(tenantId string) ActivityStream
+
+	// SetTenantLimits sets the limits of the tenant, they apply to ActivityStreams returned by ForTenant afterwards.
+	SetTenantLimits(tenantId string, limits TenantLimits)
+
+	// DeleteTenant deletes all data of the tenant and returns the number of deleted entries.
+	DeleteTenant(tenantId string) (int, error)
 }
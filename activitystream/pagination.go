@@ -15,12 +15,27 @@ func MakeTimestamp(t time.Time) int64 {
 // direction	theDirection of the previous request, this is needed for determining first and last page
 // activities	the last result
 func CreateTokens(size int, direction Direction, activities []Activity) (prev, next string) {
+	return CreateScoredTokens(size, direction, activities, "", TimeScorer)
+}
+
+// CreateScoredTokens works like CreateTokens for streams which are not sorted by time. The pivots are computed with the
+// Scorer of the stream.
+// Pinned activities are not part of the pagination and therefore ignored.
+func CreateScoredTokens(size int, direction Direction, activities []Activity, streamId string, scorer Scorer) (prev, next string) {
+	unpinned := make([]Activity, 0, len(activities))
+	for i := range activities {
+		if !activities[i].Pinned {
+			unpinned = append(unpinned, activities[i])
+		}
+	}
+	activities = unpinned
+
 	leng := len(activities)
 	if leng == 0 {
 		return
 	}
-	lastPivot := strconv.Itoa(int(MakeTimestamp(activities[leng-1].Published)))
-	firstPivot := strconv.Itoa(int(MakeTimestamp(activities[0].Published)))
+	lastPivot := strconv.FormatInt(scorer.Score(streamId, activities[leng-1]), 10)
+	firstPivot := strconv.FormatInt(scorer.Score(streamId, activities[0]), 10)
 	s := strconv.Itoa(size)
 
 	if direction == After || leng >= size {
@@ -58,6 +58,21 @@ func TestNextAndPrevTokenGeneration(t *testing.T) {
 			})
 		})
 
+		Convey("When the first activity is pinned", func() {
+			size := 2
+			pinned := createTestActivity()
+			pinned.Pinned = true
+			correctNext := fmt.Sprintf("?s=%d&after=%d", size, MakeTimestamp(activities[1].Published))
+			correctPrev := fmt.Sprintf("?s=%d&before=%d", size, timeStampNewest)
+
+			prev, next := CreateTokens(size, After, []Activity{pinned, activities[0], activities[1]})
+
+			Convey("It should ignore the pinned activity", func() {
+				So(next, ShouldEqual, correctNext)
+				So(prev, ShouldEqual, correctPrev)
+			})
+		})
+
 		Convey("When empty array of activites", func() {
 			size := 4
 			afterNotBefore := Before
@@ -0,0 +1,155 @@
+package activitystream
+
+import (
+	"sync"
+	"time"
+)
+
+// Resolver looks up the current version of objects like actors by their ID, e.g. from a profile service
+type Resolver interface {
+	// Resolve returns the objects by their ID, IDs which are unknown to the Resolver are left out
+	Resolve(ids ...string) (map[string]BaseObject, error)
+}
+
+// ResolverFunc is an adapter to use an ordinary function as Resolver
+type ResolverFunc func(ids ...string) (map[string]BaseObject, error)
+
+// Resolve calls f(ids...)
+func (f ResolverFunc) Resolve(ids ...string) (map[string]BaseObject, error) {
+	return f(ids...)
+}
+
+// Hydrate replaces actor, object and target of the activities by their current version given by the resolver.
+// The IDs of all activities are resolved in a single call. Objects without ID or unknown to the resolver are kept.
+func Hydrate(resolver Resolver, activities []Activity) error {
+	if resolver == nil || len(activities) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool)
+	ids := make([]string, 0)
+	for i := range activities {
+		for _, o := range activities[i].references() {
+			if o.Id != "" && !seen[o.Id] {
+				seen[o.Id] = true
+				ids = append(ids, o.Id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	objects, err := resolver.Resolve(ids...)
+	if err != nil {
+		return err
+	}
+	for i := range activities {
+		for _, o := range activities[i].references() {
+			if resolved, ok := objects[o.Id]; ok && o.Id != "" {
+				*o = resolved
+			}
+		}
+	}
+	return nil
+}
+
+// references returns the objects of the activity which are hydrated by Hydrate
+func (a *Activity) references() []*BaseObject {
+	return []*BaseObject{&a.Actor, &a.Object, &a.Target}
+}
+
+// CachedResolver caches the objects returned by a Resolver for a certain time. Only IDs which are not cached are
+// passed on, IDs unknown to the Resolver are cached as well.
+// It is safe for concurrent use.
+type CachedResolver struct {
+	resolver   Resolver
+	ttl        time.Duration
+	maxEntries int
+
+	mutex   sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	object  BaseObject
+	found   bool
+	expires time.Time
+}
+
+// NewCachedResolver returns a CachedResolver which keeps objects for ttl. If maxEntries is positive, the cache is
+// limited to this number of objects, expired and then arbitrary objects are evicted when it is full.
+func NewCachedResolver(resolver Resolver, ttl time.Duration, maxEntries int) *CachedResolver {
+	return &CachedResolver{
+		resolver:   resolver,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]cacheEntry),
+	}
+}
+
+// Resolve returns the cached objects and resolves the others through the underlying Resolver in a single call
+func (c *CachedResolver) Resolve(ids ...string) (map[string]BaseObject, error) {
+	objects := make(map[string]BaseObject)
+	missing := make([]string, 0)
+
+	now := time.Now()
+	c.mutex.Lock()
+	for _, id := range ids {
+		entry, ok := c.entries[id]
+		switch {
+		case !ok || now.After(entry.expires):
+			missing = append(missing, id)
+		case entry.found:
+			objects[id] = entry.object
+		}
+	}
+	c.mutex.Unlock()
+	if len(missing) == 0 {
+		return objects, nil
+	}
+
+	resolved, err := c.resolver.Resolve(missing...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	expires := time.Now().Add(c.ttl)
+	for _, id := range missing {
+		object, found := resolved[id]
+		if found {
+			objects[id] = object
+		}
+		c.evict(now)
+		c.entries[id] = cacheEntry{object: object, found: found, expires: expires}
+	}
+	return objects, nil
+}
+
+// Invalidate removes the objects from the cache, e.g. after an actor has changed its profile
+func (c *CachedResolver) Invalidate(ids ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, id := range ids {
+		delete(c.entries, id)
+	}
+}
+
+// evict makes room for one entry if the cache is full, the mutex must be held
+func (c *CachedResolver) evict(now time.Time) {
+	if c.maxEntries <= 0 || len(c.entries) < c.maxEntries {
+		return
+	}
+	for id, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, id)
+		}
+	}
+	for id := range c.entries {
+		if len(c.entries) < c.maxEntries {
+			break
+		}
+		delete(c.entries, id)
+	}
+}
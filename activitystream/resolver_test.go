@@ -0,0 +1,108 @@
+package activitystream
+
+import (
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+	"time"
+)
+
+func TestHydrate(t *testing.T) {
+	calls := 0
+	var requested []string
+	resolver := ResolverFunc(func(ids ...string) (map[string]BaseObject, error) {
+		calls++
+		requested = ids
+		return map[string]BaseObject{"ACTOR_ID": {Id: "ACTOR_ID", DisplayName: "New Name"}}, nil
+	})
+
+	Convey("Subject: Test hydration of activities", t, func() {
+		calls = 0
+		activities := []Activity{createTestActivity(), createTestActivity()}
+		activities[0].Actor.DisplayName = "Old Name"
+
+		Convey("When activities are hydrated", func() {
+			err := Hydrate(resolver, activities)
+			So(err, ShouldBeNil)
+
+			Convey("It should resolve all distinct IDs in a single call", func() {
+				So(calls, ShouldEqual, 1)
+				So(requested, ShouldResemble, []string{"ACTOR_ID", "COMMUNITY_ID"})
+			})
+			Convey("It should replace known objects and keep the others", func() {
+				So(activities[0].Actor.DisplayName, ShouldEqual, "New Name")
+				So(activities[1].Actor.DisplayName, ShouldEqual, "New Name")
+				So(activities[0].Object.Id, ShouldEqual, "COMMUNITY_ID")
+				So(activities[0].Object.ObjectType, ShouldEqual, "Community")
+				So(activities[0].Target, ShouldResemble, BaseObject{})
+			})
+		})
+		Convey("When the resolver fails", func() {
+			failing := ResolverFunc(func(ids ...string) (map[string]BaseObject, error) {
+				return nil, errors.New("unavailable")
+			})
+
+			Convey("It should return its error", func() {
+				So(Hydrate(failing, activities), ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestCachedResolver(t *testing.T) {
+	var requested [][]string
+	resolver := ResolverFunc(func(ids ...string) (map[string]BaseObject, error) {
+		requested = append(requested, ids)
+		objects := make(map[string]BaseObject)
+		for _, id := range ids {
+			if id != "UNKNOWN_ID" {
+				objects[id] = BaseObject{Id: id}
+			}
+		}
+		return objects, nil
+	})
+
+	Convey("Subject: Test caching of resolved objects", t, func() {
+		requested = nil
+		cached := NewCachedResolver(resolver, time.Minute, 0)
+
+		Convey("When objects are resolved twice", func() {
+			_, err := cached.Resolve("A", "UNKNOWN_ID")
+			So(err, ShouldBeNil)
+			objects, err := cached.Resolve("A", "B", "UNKNOWN_ID")
+			So(err, ShouldBeNil)
+
+			Convey("It should only pass on IDs which are not cached, including unknown ones", func() {
+				So(requested, ShouldResemble, [][]string{{"A", "UNKNOWN_ID"}, {"B"}})
+				So(len(objects), ShouldEqual, 2)
+				So(objects["A"].Id, ShouldEqual, "A")
+			})
+		})
+		Convey("When an object is invalidated", func() {
+			cached.Resolve("A")
+			cached.Invalidate("A")
+			cached.Resolve("A")
+
+			Convey("It should be resolved again", func() {
+				So(len(requested), ShouldEqual, 2)
+			})
+		})
+		Convey("When objects have expired", func() {
+			cached = NewCachedResolver(resolver, -time.Second, 0)
+			cached.Resolve("A")
+			cached.Resolve("A")
+
+			Convey("It should be resolved again", func() {
+				So(len(requested), ShouldEqual, 2)
+			})
+		})
+		Convey("When the cache is full", func() {
+			cached = NewCachedResolver(resolver, time.Minute, 2)
+			cached.Resolve("A", "B", "C")
+
+			Convey("It should not exceed its maximum size", func() {
+				So(len(cached.entries), ShouldEqual, 2)
+			})
+		})
+	})
+}
@@ -0,0 +1,71 @@
+package activitystream
+
+import (
+	"time"
+)
+
+// RetentionPolicy defines how many and how long activities are kept in the streams it applies to and in the database
+type RetentionPolicy struct {
+	// Pattern selects the streams by their ID, "*" matches any sequence of characters and "?" a single character,
+	// e.g. "*-out" selects all outboxes.
+	Pattern string
+	// MaxSize is the maximum number of activities of the stream, those with the lowest score are removed once it is
+	// exceeded.
+	// Zero applies the maximum size of the ActivityStream, see SetMaxStreamSize, a negative number means no limit.
+	MaxSize int
+	// MaxAge removes activities from the stream once their score is older than MaxAge. The score must be a unix
+	// timestamp in milliseconds, like given by TimeScorer. Zero keeps activities regardless of their age.
+	MaxAge time.Duration
+	// ActivityTTL lets activities expire in the database after this time. It is set when an activity is stored by
+	// adding it to streams, the longest TTL of these streams applies. Zero keeps activities forever, as does adding
+	// them to a stream without policy.
+	ActivityTTL time.Duration
+	// CollectGarbage removes activities from the database once they have been removed from the stream and are
+	// referenced by no other stream or pin. Only activities stored by adding them to streams are counted.
+	CollectGarbage bool
+}
+
+// Matches reports whether the policy applies to the stream
+func (p RetentionPolicy) Matches(streamId string) bool {
+	return matchPattern(p.Pattern, streamId)
+}
+
+// RetentionPolicies is a list of policies, of which the first matching applies to a stream
+type RetentionPolicies []RetentionPolicy
+
+// For returns the policy which applies to the stream and false if there is none
+func (policies RetentionPolicies) For(streamId string) (RetentionPolicy, bool) {
+	for _, p := range policies {
+		if p.Matches(streamId) {
+			return p, true
+		}
+	}
+	return RetentionPolicy{}, false
+}
+
+// matchPattern matches s against a pattern of "*" for any sequence of characters and "?" for a single character
+func matchPattern(pattern, s string) bool {
+	p, i := []rune(pattern), []rune(s)
+	// position after the last "*" in the pattern and the position in s it has been matched up to
+	star, matched := -1, 0
+	pi, si := 0, 0
+	for si < len(i) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == i[si]):
+			pi++
+			si++
+		case pi < len(p) && p[pi] == '*':
+			star, matched = pi+1, si
+			pi++
+		case star >= 0:
+			matched++
+			pi, si = star, matched
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
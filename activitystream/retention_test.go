@@ -0,0 +1,49 @@
+package activitystream
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+	"time"
+)
+
+func TestRetentionPolicies(t *testing.T) {
+	Convey("Subject: Test matching of retention policies", t, func() {
+		Convey("When patterns are matched", func() {
+			Convey("It should support * and ? anywhere", func() {
+				So(matchPattern("*-out", "ACTOR_ID-out"), ShouldBeTrue)
+				So(matchPattern("*-out", "http://example.com/users/alice-out"), ShouldBeTrue)
+				So(matchPattern("*-out", "ACTOR_ID"), ShouldBeFalse)
+				So(matchPattern("team-?-*", "team-a-feed"), ShouldBeTrue)
+				So(matchPattern("team-?-*", "team-ab-feed"), ShouldBeFalse)
+				So(matchPattern("*", ""), ShouldBeTrue)
+				So(matchPattern("a*b*c", "aXbYbZc"), ShouldBeTrue)
+				So(matchPattern("a*b*c", "aXbYbZ"), ShouldBeFalse)
+				So(matchPattern("exact", "exact"), ShouldBeTrue)
+			})
+		})
+		Convey("When several policies match a stream", func() {
+			policies := RetentionPolicies{
+				{Pattern: "*-out", MaxAge: time.Hour},
+				{Pattern: "*", MaxAge: time.Minute},
+			}
+
+			Convey("It should apply the first", func() {
+				policy, ok := policies.For("ACTOR_ID-out")
+				So(ok, ShouldBeTrue)
+				So(policy.MaxAge, ShouldEqual, time.Hour)
+				policy, ok = policies.For("ACTOR_ID")
+				So(ok, ShouldBeTrue)
+				So(policy.MaxAge, ShouldEqual, time.Minute)
+			})
+		})
+		Convey("When no policy matches a stream", func() {
+			policies := RetentionPolicies{{Pattern: "*-out", MaxAge: time.Hour}}
+
+			Convey("It should return the zero policy", func() {
+				policy, ok := policies.For("ACTOR_ID")
+				So(ok, ShouldBeFalse)
+				So(policy, ShouldResemble, RetentionPolicy{})
+			})
+		})
+	})
+}
@@ -0,0 +1,53 @@
+package activitystream
+
+import (
+	"math"
+	"time"
+)
+
+// Scorer computes the score of an activity within a stream. A stream is sorted by descending score and the pivots used
+// for pagination are scores as well.
+// Pagination tokens are created by scoring the activities of a page again, therefore the score must only depend on the
+// stream and the activity, not on the time of scoring.
+type Scorer interface {
+	Score(streamId string, activity Activity) int64
+}
+
+// ScorerFunc is an adapter to allow the use of ordinary functions as Scorer.
+type ScorerFunc func(streamId string, activity Activity) int64
+
+// Score calls f(streamId, activity).
+func (f ScorerFunc) Score(streamId string, activity Activity) int64 {
+	return f(streamId, activity)
+}
+
+// TimeScorer scores an activity by its time of publication in unix milliseconds, independent of the stream.
+// It is the Scorer used by default.
+var TimeScorer Scorer = ScorerFunc(func(streamId string, activity Activity) int64 {
+	return MakeTimestamp(activity.Published)
+})
+
+// DecayScorer ranks activities by their relevance, which is the affinity of the stream to the activity decaying over time.
+// The relevance halves every HalfLife, so an activity with affinity 2 is ranked as if it was published one HalfLife later.
+// Since all relevances decay at the same rate their order does not change over time, which keeps the score stable.
+type DecayScorer struct {
+	// HalfLife is the duration after which the relevance of an activity has halved
+	HalfLife time.Duration
+	// Affinity returns the weight of the activity within the stream, e.g. how close the stream's owner is to the actor.
+	// Weights must be positive, a nil Affinity or a weight <= 0 counts as 1.
+	Affinity func(streamId string, activity Activity) float64
+}
+
+// Score returns the publication time in unix milliseconds shifted by HalfLife * log2(affinity).
+func (s DecayScorer) Score(streamId string, activity Activity) int64 {
+	score := MakeTimestamp(activity.Published)
+	if s.Affinity == nil {
+		return score
+	}
+	affinity := s.Affinity(streamId, activity)
+	if affinity <= 0 {
+		return score
+	}
+	halfLife := float64(s.HalfLife / time.Millisecond)
+	return score + int64(halfLife*math.Log2(affinity))
+}
@@ -0,0 +1,58 @@
+package activitystream
+
+import (
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+	"time"
+)
+
+func TestScorers(t *testing.T) {
+	activity := createTestActivity()
+
+	Convey("Subject: Test Scorers", t, func() {
+		Convey("When TimeScorer is used", func() {
+			Convey("It should return the publication time in unix milliseconds", func() {
+				So(TimeScorer.Score("STREAM_ID", activity), ShouldEqual, MakeTimestamp(activity.Published))
+				So(TimeScorer.Score("STREAM_ID", activity), ShouldEqual, activity.Score())
+			})
+		})
+
+		Convey("When DecayScorer is used", func() {
+			scorer := DecayScorer{
+				HalfLife: time.Hour,
+				Affinity: func(streamId string, a Activity) float64 {
+					if a.Actor.Id == "BEST_FRIEND_ID" {
+						return 2
+					}
+					return 1
+				},
+			}
+
+			Convey("It should rank an activity with affinity 2 as if it was published one half-life later", func() {
+				friendActivity := createTestActivity()
+				friendActivity.Actor.Id = "BEST_FRIEND_ID"
+				So(scorer.Score("STREAM_ID", friendActivity), ShouldEqual, MakeTimestamp(friendActivity.Published.Add(time.Hour)))
+			})
+			Convey("It should not change the score of an activity with affinity 1", func() {
+				So(scorer.Score("STREAM_ID", activity), ShouldEqual, MakeTimestamp(activity.Published))
+			})
+			Convey("It should ignore weights which are not positive", func() {
+				scorer.Affinity = func(string, Activity) float64 { return 0 }
+				So(scorer.Score("STREAM_ID", activity), ShouldEqual, MakeTimestamp(activity.Published))
+			})
+		})
+
+		Convey("When tokens are created with a Scorer", func() {
+			scorer := ScorerFunc(func(streamId string, a Activity) int64 {
+				return int64(len(a.Id))
+			})
+
+			Convey("It should use the scores as pivots", func() {
+				prev, next := CreateScoredTokens(1, After, []Activity{activity}, "STREAM_ID", scorer)
+				So(prev, ShouldEqual, fmt.Sprintf("?s=1&before=%d", len(activity.Id)))
+				So(next, ShouldEqual, fmt.Sprintf("?s=1&after=%d", len(activity.Id)))
+			})
+		})
+	})
+}
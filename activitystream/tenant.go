@@ -0,0 +1,39 @@
+package activitystream
+
+import (
+	"time"
+)
+
+// TenantLimits caps the resources of a tenant, see ActivityStream.ForTenant. Zero values set no limit.
+type TenantLimits struct {
+	// MaxStreamSize is the maximum number of activities of every stream of the tenant. It caps the maximum size of
+	// the ActivityStream and the MaxSize of retention policies.
+	MaxStreamSize int
+	// MaxAge removes activities from the streams of the tenant once their score is older, it caps the MaxAge of
+	// retention policies
+	MaxAge time.Duration
+	// ActivityTTL lets activities of the tenant expire in the database at the latest after this time, it caps the
+	// ActivityTTL of retention policies. Like these it only applies to activities stored by adding them to streams.
+	ActivityTTL time.Duration
+}
+
+// Limit returns the policy capped by the limits. The MaxSize of the policy must be resolved before, zero is taken as
+// no limit.
+func (l TenantLimits) Limit(policy RetentionPolicy) RetentionPolicy {
+	if l.MaxStreamSize > 0 && (policy.MaxSize <= 0 || policy.MaxSize > l.MaxStreamSize) {
+		policy.MaxSize = l.MaxStreamSize
+	}
+	if l.MaxAge > 0 && (policy.MaxAge <= 0 || policy.MaxAge > l.MaxAge) {
+		policy.MaxAge = l.MaxAge
+	}
+	if l.ActivityTTL > 0 && (policy.ActivityTTL <= 0 || policy.ActivityTTL > l.ActivityTTL) {
+		policy.ActivityTTL = l.ActivityTTL
+	}
+	return policy
+}
+
+// Within returns the limits capped by the limits of the parent, e.g. those of a sub-tenant by those of its tenant
+func (l TenantLimits) Within(parent TenantLimits) TenantLimits {
+	capped := parent.Limit(RetentionPolicy{MaxSize: l.MaxStreamSize, MaxAge: l.MaxAge, ActivityTTL: l.ActivityTTL})
+	return TenantLimits{MaxStreamSize: capped.MaxSize, MaxAge: capped.MaxAge, ActivityTTL: capped.ActivityTTL}
+}
@@ -0,0 +1,61 @@
+package activitystream
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+	"time"
+)
+
+func TestTenantLimits(t *testing.T) {
+	Convey("Subject: Test capping retention policies by tenant limits", t, func() {
+		limits := TenantLimits{MaxStreamSize: 10, MaxAge: time.Hour, ActivityTTL: 24 * time.Hour}
+
+		Convey("When the policy sets no limit", func() {
+			policy := limits.Limit(RetentionPolicy{Pattern: "*", MaxSize: -1})
+
+			Convey("It should apply the limits of the tenant", func() {
+				So(policy.Pattern, ShouldEqual, "*")
+				So(policy.MaxSize, ShouldEqual, 10)
+				So(policy.MaxAge, ShouldEqual, time.Hour)
+				So(policy.ActivityTTL, ShouldEqual, 24*time.Hour)
+			})
+		})
+		Convey("When the policy exceeds the limits", func() {
+			policy := limits.Limit(RetentionPolicy{MaxSize: 100, MaxAge: 2 * time.Hour, ActivityTTL: 48 * time.Hour})
+
+			Convey("It should cap it", func() {
+				So(policy.MaxSize, ShouldEqual, 10)
+				So(policy.MaxAge, ShouldEqual, time.Hour)
+				So(policy.ActivityTTL, ShouldEqual, 24*time.Hour)
+			})
+		})
+		Convey("When the policy is within the limits", func() {
+			policy := limits.Limit(RetentionPolicy{MaxSize: 5, MaxAge: time.Minute, ActivityTTL: time.Minute})
+
+			Convey("It should keep it", func() {
+				So(policy.MaxSize, ShouldEqual, 5)
+				So(policy.MaxAge, ShouldEqual, time.Minute)
+				So(policy.ActivityTTL, ShouldEqual, time.Minute)
+			})
+		})
+		Convey("When no limits are set", func() {
+			policy := TenantLimits{}.Limit(RetentionPolicy{MaxSize: -1})
+
+			Convey("It should keep the policy unlimited", func() {
+				So(policy.MaxSize, ShouldEqual, -1)
+				So(policy.MaxAge, ShouldEqual, 0)
+				So(policy.ActivityTTL, ShouldEqual, 0)
+			})
+		})
+		Convey("When limits are nested within the limits", func() {
+			nested := TenantLimits{MaxStreamSize: 5, MaxAge: 2 * time.Hour}.Within(limits)
+
+			Convey("It should cap them and inherit the missing ones", func() {
+				So(nested.MaxStreamSize, ShouldEqual, 5)
+				So(nested.MaxAge, ShouldEqual, time.Hour)
+				So(nested.ActivityTTL, ShouldEqual, 24*time.Hour)
+				So(TenantLimits{}.Within(limits), ShouldResemble, limits)
+			})
+		})
+	})
+}
@@ -0,0 +1,32 @@
+package activitystream
+
+import (
+	"time"
+)
+
+// DefaultTombstoneTTL is the time a tombstone replaces a deleted activity by default, before it disappears from
+// its streams. It can be adjusted on the ActivityStream using its method SetTombstoneTTL.
+const DefaultTombstoneTTL = 30 * 24 * time.Hour
+
+// IsTombstone reports whether the activity has been deleted and is only a tombstone, see Tombstone
+func (a *Activity) IsTombstone() bool {
+	return a.Deleted != nil
+}
+
+// Tombstone returns the tombstone which replaces the activity once it has been deleted. It keeps ID, verb, version,
+// time of publication, the ID of the actor and the audience, so it is sorted and filtered like the activity it
+// replaces. All content is dropped.
+func (a *Activity) Tombstone(deleted time.Time) Activity {
+	return Activity{
+		Id:        a.Id,
+		Published: a.Published,
+		Verb:      a.Verb,
+		Actor:     BaseObject{Id: a.Actor.Id},
+		To:        a.To,
+		Cc:        a.Cc,
+		Bto:       a.Bto,
+		Bcc:       a.Bcc,
+		Version:   a.Version,
+		Deleted:   &deleted,
+	}
+}
@@ -0,0 +1,161 @@
+package activitystream
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes a violated rule of a single field of an activity
+type FieldError struct {
+	// Path is the path of the field as named in JSON, e.g. "object.image.url" or "tags[1].url"
+	Path    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors is returned by Validate and lists all violated rules of an activity
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i := range errs {
+		messages[i] = errs[i].Error()
+	}
+	return "invalid activity: " + strings.Join(messages, "; ")
+}
+
+// Is reports whether target is ErrInvalidActivity
+func (errs ValidationErrors) Is(target error) bool {
+	return target == ErrInvalidActivity
+}
+
+// Rule checks an activity and returns the violations it has found, or none if the activity is valid
+type Rule func(a *Activity) ValidationErrors
+
+// Validator is a set of rules an activity must follow
+type Validator []Rule
+
+// DefaultValidator is the rule set used by Activity.Validate
+var DefaultValidator = Validator{RequireId(), RequireVerb(), RequireActorId(), ValidURLs()}
+
+// Validate checks the activity against all rules. It returns ValidationErrors listing every violation, or nil if the
+// activity is valid.
+func (v Validator) Validate(a *Activity) error {
+	var errs ValidationErrors
+	for _, rule := range v {
+		errs = append(errs, rule(a)...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Validate checks the activity against the DefaultValidator
+func (a *Activity) Validate() error {
+	return DefaultValidator.Validate(a)
+}
+
+// RequireId requires the activity to have an ID
+func RequireId() Rule {
+	return func(a *Activity) ValidationErrors {
+		if a.Id == "" {
+			return ValidationErrors{{"_id", "is required"}}
+		}
+		return nil
+	}
+}
+
+// RequireVerb requires the activity to have a verb
+func RequireVerb() Rule {
+	return func(a *Activity) ValidationErrors {
+		if a.Verb == "" {
+			return ValidationErrors{{"verb", "is required"}}
+		}
+		return nil
+	}
+}
+
+// RequireActorId requires the actor of the activity to have an ID
+func RequireActorId() Rule {
+	return func(a *Activity) ValidationErrors {
+		if a.Actor.Id == "" {
+			return ValidationErrors{{"actor.id", "is required"}}
+		}
+		return nil
+	}
+}
+
+// AllowObjectTypes restricts the object type of the object of the activity to the given types
+func AllowObjectTypes(types ...ObjectType) Rule {
+	return func(a *Activity) ValidationErrors {
+		for _, t := range types {
+			if a.Object.ObjectType == t {
+				return nil
+			}
+		}
+		return ValidationErrors{{"object.objectType", fmt.Sprintf("%q is not allowed", a.Object.ObjectType)}}
+	}
+}
+
+// ValidURLs requires all URLs of the objects of the activity and their images to be absolute http or https URLs.
+// Empty URLs are allowed.
+func ValidURLs() Rule {
+	return func(a *Activity) ValidationErrors {
+		var errs ValidationErrors
+		check := func(path, value string) {
+			if value == "" {
+				return
+			}
+			u, err := url.Parse(value)
+			if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, FieldError{path, "is not an absolute http(s) URL"})
+			}
+		}
+		a.eachObject(func(path string, o *BaseObject) {
+			check(path+".url", o.URL)
+			check(path+".image.url", o.Image.URL)
+		})
+		return errs
+	}
+}
+
+// MaxContentLength limits the content of the objects of the activity to the given number of characters
+func MaxContentLength(max int) Rule {
+	return func(a *Activity) ValidationErrors {
+		var errs ValidationErrors
+		a.eachObject(func(path string, o *BaseObject) {
+			if utf8.RuneCountInString(o.Content) > max {
+				errs = append(errs, FieldError{path + ".content", fmt.Sprintf("exceeds %d characters", max)})
+			}
+		})
+		return errs
+	}
+}
+
+// eachObject calls f with every object of the activity which is set, along with its path
+func (a *Activity) eachObject(f func(path string, o *BaseObject)) {
+	f("actor", &a.Actor)
+	f("object", &a.Object)
+	f("target", &a.Target)
+	optional := []struct {
+		path   string
+		object *BaseObject
+	}{
+		{"context", a.Context}, {"result", a.Result}, {"instrument", a.Instrument},
+		{"generator", a.Generator}, {"provider", a.Provider}, {"location", a.Location},
+	}
+	for _, o := range optional {
+		if o.object != nil {
+			f(o.path, o.object)
+		}
+	}
+	for i := range a.Tags {
+		f(fmt.Sprintf("tags[%d]", i), &a.Tags[i])
+	}
+}
@@ -0,0 +1,58 @@
+package activitystream
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	testing "testing"
+)
+
+func TestValidate(t *testing.T) {
+	Convey("Subject: Test validation of activities", t, func() {
+		activity := createTestActivity()
+
+		Convey("When a valid activity is validated", func() {
+			Convey("It should return no error", func() {
+				So(activity.Validate(), ShouldBeNil)
+			})
+		})
+		Convey("When an activity violates several rules", func() {
+			activity.Verb = ""
+			activity.Actor.Id = ""
+			activity.Object.Image.URL = "/relative.png"
+			activity.Tags = []BaseObject{{ObjectType: Mention, URL: "ftp://example.com/users/bob"}}
+			err := activity.Validate()
+
+			Convey("It should list every violation with the path of its field", func() {
+				errs, ok := err.(ValidationErrors)
+				So(ok, ShouldBeTrue)
+				paths := make([]string, len(errs))
+				for i := range errs {
+					paths[i] = errs[i].Path
+				}
+				So(paths, ShouldResemble, []string{"verb", "actor.id", "object.image.url", "tags[0].url"})
+				So(err.Error(), ShouldStartWith, "invalid activity: verb: is required;")
+			})
+		})
+		Convey("When a custom rule set is used", func() {
+			validator := Validator{AllowObjectTypes("Community", "Group"), MaxContentLength(5)}
+			activity.Context = &BaseObject{Content: strings.Repeat("ä", 6)}
+
+			Convey("It should apply its rules only", func() {
+				err := validator.Validate(&activity)
+				So(err, ShouldResemble, ValidationErrors{{"context.content", "exceeds 5 characters"}})
+
+				activity.Object.ObjectType = "Note"
+				activity.Context = nil
+				err = validator.Validate(&activity)
+				So(err, ShouldResemble, ValidationErrors{{"object.objectType", `"Note" is not allowed`}})
+			})
+		})
+		Convey("When an empty rule set is used", func() {
+			var validator Validator
+
+			Convey("It should accept any activity", func() {
+				So(validator.Validate(&Activity{}), ShouldBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,161 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"github.com/chrisport/go-activitystream/activitystream"
+	"io"
+	"net/url"
+	"time"
+)
+
+const (
+	// AtomNamespace is the XML namespace of Atom 1.0
+	AtomNamespace = "http://www.w3.org/2005/Atom"
+	// ActivityNamespace is the XML namespace of the Atom Activity extension
+	ActivityNamespace = "http://activitystrea.ms/spec/1.0/"
+	// ActivitySchema is the base IRI of the verbs and object types of the Atom Activity extension
+	ActivitySchema = "http://activitystrea.ms/schema/1.0/"
+	// AtomContentType is the media type of Atom documents
+	AtomContentType = "application/atom+xml"
+)
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"feed"`
+	Namespace string      `xml:"xmlns,attr"`
+	Activity  string      `xml:"xmlns:activity,attr"`
+	Id        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Subtitle  string      `xml:"subtitle,omitempty"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Id        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Author    atomAuthor  `xml:"author"`
+	Links     []atomLink  `xml:"link"`
+	Content   *atomText   `xml:"content"`
+	Verb      string      `xml:"activity:verb,omitempty"`
+	Object    *atomObject `xml:"activity:object"`
+	Target    *atomObject `xml:"activity:target"`
+}
+
+type atomAuthor struct {
+	Name       string     `xml:"name"`
+	URI        string     `xml:"uri,omitempty"`
+	ObjectType string     `xml:"activity:object-type,omitempty"`
+	Links      []atomLink `xml:"link"`
+}
+
+type atomObject struct {
+	Id         string     `xml:"id"`
+	Title      string     `xml:"title,omitempty"`
+	ObjectType string     `xml:"activity:object-type,omitempty"`
+	Content    *atomText  `xml:"content"`
+	Links      []atomLink `xml:"link"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// WriteAtom writes the page as Atom 1.0 feed. Every activity becomes an entry described with the Atom Activity
+// extension, the previous and the next page are linked with rel="prev" and rel="next".
+func WriteAtom(w io.Writer, feed Feed, page Page) error {
+	entries := page.entries()
+	doc := atomFeed{
+		Namespace: AtomNamespace,
+		Activity:  ActivityNamespace,
+		Id:        feed.URL,
+		Title:     feed.Title,
+		Subtitle:  feed.Description,
+		Updated:   updated(page).Format(time.RFC3339),
+		Links:     []atomLink{{Rel: "self", Type: AtomContentType, Href: feed.URL}},
+		Entries:   make([]atomEntry, len(entries)),
+	}
+	prev, next := feed.links(page)
+	if prev != "" {
+		doc.Links = append(doc.Links, atomLink{Rel: "prev", Type: AtomContentType, Href: prev})
+	}
+	if next != "" {
+		doc.Links = append(doc.Links, atomLink{Rel: "next", Type: AtomContentType, Href: next})
+	}
+
+	for i, a := range entries {
+		entry := atomEntry{
+			Id:        feed.entryId(a),
+			Title:     title(a),
+			Published: a.Published.Format(time.RFC3339),
+			Updated:   lastModified(a).Format(time.RFC3339),
+			Author: atomAuthor{
+				Name:       name(a.Actor),
+				URI:        a.Actor.URL,
+				ObjectType: schemaIRI(string(a.Actor.ObjectType)),
+				Links:      imageLinks(a.Actor),
+			},
+			Verb:   schemaIRI(a.Verb),
+			Object: toAtomObject(a.Object),
+			Target: toAtomObject(a.Target),
+		}
+		if a.Object.URL != "" {
+			entry.Links = []atomLink{{Rel: "alternate", Type: "text/html", Href: a.Object.URL}}
+		}
+		if a.Object.Content != "" {
+			entry.Content = &atomText{Type: "html", Text: a.Object.Content}
+		}
+		doc.Entries[i] = entry
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(doc)
+}
+
+func toAtomObject(o activitystream.BaseObject) *atomObject {
+	if o.Id == "" {
+		return nil
+	}
+	object := &atomObject{
+		Id:         o.Id,
+		Title:      o.DisplayName,
+		ObjectType: schemaIRI(string(o.ObjectType)),
+		Links:      imageLinks(o),
+	}
+	if o.Content != "" {
+		object.Content = &atomText{Type: "html", Text: o.Content}
+	}
+	if o.URL != "" {
+		object.Links = append(object.Links, atomLink{Rel: "alternate", Type: "text/html", Href: o.URL})
+	}
+	return object
+}
+
+func imageLinks(o activitystream.BaseObject) []atomLink {
+	if o.Image.URL == "" {
+		return nil
+	}
+	return []atomLink{{Rel: "preview", Href: o.Image.URL}}
+}
+
+// schemaIRI returns verbs and object types which are no absolute IRI as IRI of the activitystrea.ms 1.0 schema
+func schemaIRI(name string) string {
+	if name == "" {
+		return ""
+	}
+	if u, err := url.Parse(name); err == nil && u.IsAbs() {
+		return name
+	}
+	return ActivitySchema + name
+}
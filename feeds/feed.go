@@ -0,0 +1,104 @@
+// Package feeds renders pages of a stream as documents for feed readers: Atom 1.0 including the Atom Activity
+// extension, RSS 2.0 and JSON Feed 1.1.
+// The pages are linked with each other through the pagination tokens of the stream, see activitystream.CreateTokens.
+package feeds
+
+import (
+	"github.com/chrisport/go-activitystream/activitystream"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Feed describes the stream whose pages are rendered
+type Feed struct {
+	StreamId    string
+	Title       string
+	Description string
+	// URL is the address the feed is served at without query, the pagination tokens are appended to it
+	URL string
+	// Scorer must be the Scorer of the stream, nil stands for the default TimeScorer
+	Scorer activitystream.Scorer
+}
+
+// Page is a page of the stream as returned by GetStream, along with the pagination it was requested with.
+// Tombstones are only used for pagination, they are not rendered.
+type Page struct {
+	Size       int
+	Direction  activitystream.Direction
+	Activities []activitystream.Activity
+}
+
+// entries returns the activities of the page which are rendered, tombstones of deleted activities are left out
+func (p Page) entries() []activitystream.Activity {
+	entries := make([]activitystream.Activity, 0, len(p.Activities))
+	for i := range p.Activities {
+		if !p.Activities[i].IsTombstone() {
+			entries = append(entries, p.Activities[i])
+		}
+	}
+	return entries
+}
+
+// links returns the URLs of the previous and the next page
+func (f Feed) links(page Page) (prev, next string) {
+	scorer := f.Scorer
+	if scorer == nil {
+		scorer = activitystream.TimeScorer
+	}
+	prev, next = activitystream.CreateScoredTokens(page.Size, page.Direction, page.Activities, f.StreamId, scorer)
+	if prev != "" {
+		prev = f.URL + prev
+	}
+	if next != "" {
+		next = f.URL + next
+	}
+	return
+}
+
+// entryId returns the ID of an activity as IRI, IDs which are no absolute IRI are appended as fragment to the feed URL
+func (f Feed) entryId(activity activitystream.Activity) string {
+	if u, err := url.Parse(activity.Id); err == nil && u.IsAbs() {
+		return activity.Id
+	}
+	return f.URL + "#" + activity.Id
+}
+
+// updated returns the time of the latest change of an activity in the page, or now if the page is empty
+func updated(page Page) time.Time {
+	var t time.Time
+	for i := range page.Activities {
+		if modified := lastModified(page.Activities[i]); modified.After(t) {
+			t = modified
+		}
+	}
+	if t.IsZero() {
+		return time.Now().UTC()
+	}
+	return t
+}
+
+// lastModified returns the time the activity was updated, or published if it has never been updated
+func lastModified(activity activitystream.Activity) time.Time {
+	if activity.Updated != nil {
+		return *activity.Updated
+	}
+	return activity.Published
+}
+
+// title returns a human readable summary of the activity like "Some Person post Some Note"
+func title(activity activitystream.Activity) string {
+	parts := []string{name(activity.Actor), activity.Verb, name(activity.Object)}
+	if target := name(activity.Target); target != "" {
+		parts = append(parts, "to", target)
+	}
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// name returns the display name of an object or its ID if it has none
+func name(object activitystream.BaseObject) string {
+	if object.DisplayName != "" {
+		return object.DisplayName
+	}
+	return object.Id
+}
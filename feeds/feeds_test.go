@@ -0,0 +1,157 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/chrisport/go-activitystream/activitystream"
+	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo/bson"
+	testing "testing"
+	"time"
+)
+
+func TestAtom(t *testing.T) {
+	feed := createTestFeed()
+	page := createTestPage()
+
+	Convey("Subject: Test rendering a page as Atom", t, func() {
+		var buf bytes.Buffer
+		err := WriteAtom(&buf, feed, page)
+		So(err, ShouldBeNil)
+
+		var parsed struct {
+			Links []struct {
+				Rel  string `xml:"rel,attr"`
+				Href string `xml:"href,attr"`
+			} `xml:"http://www.w3.org/2005/Atom link"`
+			Entries []struct {
+				Id     string `xml:"http://www.w3.org/2005/Atom id"`
+				Title  string `xml:"http://www.w3.org/2005/Atom title"`
+				Author string `xml:"http://www.w3.org/2005/Atom author>name"`
+				Verb   string `xml:"http://activitystrea.ms/spec/1.0/ verb"`
+				Object struct {
+					Id         string `xml:"http://www.w3.org/2005/Atom id"`
+					ObjectType string `xml:"http://activitystrea.ms/spec/1.0/ object-type"`
+				} `xml:"http://activitystrea.ms/spec/1.0/ object"`
+			} `xml:"http://www.w3.org/2005/Atom entry"`
+		}
+		So(xml.Unmarshal(buf.Bytes(), &parsed), ShouldBeNil)
+
+		Convey("When a full page is rendered", func() {
+			Convey("It should contain an entry with Atom Activity extension for every activity", func() {
+				So(len(parsed.Entries), ShouldEqual, 2)
+				So(parsed.Entries[0].Id, ShouldEqual, feed.URL+"#"+page.Activities[0].Id)
+				So(parsed.Entries[0].Title, ShouldEqual, "Some Person post Some Note")
+				So(parsed.Entries[0].Author, ShouldEqual, "Some Person")
+				So(parsed.Entries[0].Verb, ShouldEqual, "http://activitystrea.ms/schema/1.0/post")
+				So(parsed.Entries[0].Object.Id, ShouldEqual, "NOTE_ID")
+				So(parsed.Entries[0].Object.ObjectType, ShouldEqual, "http://activitystrea.ms/schema/1.0/note")
+			})
+			Convey("It should link the previous and the next page", func() {
+				So(len(parsed.Links), ShouldEqual, 3)
+				So(parsed.Links[0].Rel, ShouldEqual, "self")
+				So(parsed.Links[1].Rel, ShouldEqual, "prev")
+				So(parsed.Links[1].Href, ShouldEqual, fmt.Sprintf("%s?s=2&before=%d", feed.URL, page.Activities[0].Score()))
+				So(parsed.Links[2].Rel, ShouldEqual, "next")
+				So(parsed.Links[2].Href, ShouldEqual, fmt.Sprintf("%s?s=2&after=%d", feed.URL, page.Activities[1].Score()))
+			})
+		})
+		Convey("When an activity has no verb", func() {
+			page := createTestPage()
+			page.Activities[0].Verb = ""
+			var buf bytes.Buffer
+			So(WriteAtom(&buf, feed, page), ShouldBeNil)
+
+			Convey("It should not contain an empty verb", func() {
+				So(bytes.Count(buf.Bytes(), []byte("<activity:verb>")), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestRSS(t *testing.T) {
+	feed := createTestFeed()
+	page := createTestPage()
+
+	Convey("Subject: Test rendering a page as RSS", t, func() {
+		var buf bytes.Buffer
+		err := WriteRSS(&buf, feed, page)
+		So(err, ShouldBeNil)
+
+		var parsed struct {
+			Version string `xml:"version,attr"`
+			Channel struct {
+				Title string `xml:"title"`
+				Links []struct {
+					Rel  string `xml:"rel,attr"`
+					Href string `xml:"href,attr"`
+				} `xml:"http://www.w3.org/2005/Atom link"`
+				Items []struct {
+					Title   string `xml:"title"`
+					Link    string `xml:"link"`
+					Guid    string `xml:"guid"`
+					PubDate string `xml:"pubDate"`
+				} `xml:"item"`
+			} `xml:"channel"`
+		}
+		So(xml.Unmarshal(buf.Bytes(), &parsed), ShouldBeNil)
+
+		Convey("When a full page is rendered", func() {
+			Convey("It should contain an item for every activity", func() {
+				So(parsed.Version, ShouldEqual, "2.0")
+				So(parsed.Channel.Title, ShouldEqual, feed.Title)
+				So(len(parsed.Channel.Items), ShouldEqual, 2)
+				So(parsed.Channel.Items[0].Title, ShouldEqual, "Some Person post Some Note")
+				So(parsed.Channel.Items[0].Link, ShouldEqual, "http://example.com/notes/1")
+				So(parsed.Channel.Items[0].Guid, ShouldEqual, feed.URL+"#"+page.Activities[0].Id)
+				So(parsed.Channel.Items[0].PubDate, ShouldEqual, page.Activities[0].Published.Format(time.RFC1123Z))
+			})
+			Convey("It should link the previous and the next page", func() {
+				So(len(parsed.Channel.Links), ShouldEqual, 3)
+				So(parsed.Channel.Links[1].Rel, ShouldEqual, "prev")
+				So(parsed.Channel.Links[2].Rel, ShouldEqual, "next")
+				So(parsed.Channel.Links[2].Href, ShouldEqual, fmt.Sprintf("%s?s=2&after=%d", feed.URL, page.Activities[1].Score()))
+			})
+		})
+	})
+}
+
+// ************* HELPER METHODS *************
+func createTestFeed() Feed {
+	return Feed{
+		StreamId: "ACTOR_ID-out",
+		Title:    "Activities of Some Person",
+		URL:      "http://example.com/ACTOR_ID/feed",
+	}
+}
+
+func createTestPage() Page {
+	start := time.Now().UTC()
+	page := Page{Size: 2, Direction: activitystream.After, Activities: make([]activitystream.Activity, 2)}
+	for i := range page.Activities {
+		page.Activities[i] = createTestActivity()
+		page.Activities[i].Published = start.Add(-time.Duration(i) * time.Second)
+	}
+	return page
+}
+
+func createTestActivity() activitystream.Activity {
+	var a activitystream.Activity
+	a.Id = bson.NewObjectId().Hex()
+	a.Published = time.Now().UTC()
+	a.Verb = "post"
+	a.Actor = activitystream.BaseObject{}
+	a.Actor.Id = "ACTOR_ID"
+	a.Actor.ObjectType = "person"
+	a.Actor.DisplayName = "Some Person"
+	a.Actor.Image = activitystream.Image{URL: "http://example.com/avatar.png"}
+
+	a.Object = activitystream.BaseObject{}
+	a.Object.ObjectType = "note"
+	a.Object.Id = "NOTE_ID"
+	a.Object.DisplayName = "Some Note"
+	a.Object.URL = "http://example.com/notes/1"
+	a.Object.Content = "<p>Hello</p>"
+	return a
+}
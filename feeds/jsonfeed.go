@@ -0,0 +1,94 @@
+package feeds
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+const (
+	// JSONFeedVersion is the version URL of JSON Feed 1.1
+	JSONFeedVersion = "https://jsonfeed.org/version/1.1"
+	// JSONFeedContentType is the media type of JSON Feed documents
+	JSONFeedContentType = "application/feed+json"
+)
+
+// JSONFeed is a page of a stream as JSON Feed 1.1 document
+type JSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	NextURL     string         `json:"next_url,omitempty"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+// JSONFeedItem is an activity as item of a JSON Feed
+type JSONFeedItem struct {
+	Id            string           `json:"id"`
+	URL           string           `json:"url,omitempty"`
+	Title         string           `json:"title,omitempty"`
+	ContentHTML   string           `json:"content_html,omitempty"`
+	ContentText   string           `json:"content_text,omitempty"`
+	Image         string           `json:"image,omitempty"`
+	DatePublished string           `json:"date_published,omitempty"`
+	DateModified  string           `json:"date_modified,omitempty"`
+	Authors       []JSONFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+// JSONFeedAuthor is the actor of an activity as author of a JSON Feed item
+type JSONFeedAuthor struct {
+	Name   string `json:"name,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+// NewJSONFeed converts the page to a JSON Feed. The actor of an activity becomes the author of its item, content,
+// URL and image of the object become those of the item. JSON Feed only links the next page through next_url.
+func NewJSONFeed(feed Feed, page Page) JSONFeed {
+	entries := page.entries()
+	doc := JSONFeed{
+		Version:     JSONFeedVersion,
+		Title:       feed.Title,
+		FeedURL:     feed.URL,
+		Description: feed.Description,
+		Items:       make([]JSONFeedItem, len(entries)),
+	}
+	_, doc.NextURL = feed.links(page)
+
+	for i, a := range entries {
+		item := JSONFeedItem{
+			Id:          a.Id,
+			URL:         a.Object.URL,
+			Title:       title(a),
+			ContentHTML: a.Object.Content,
+			Image:       a.Object.Image.URL,
+			Authors: []JSONFeedAuthor{{
+				Name:   name(a.Actor),
+				URL:    a.Actor.URL,
+				Avatar: a.Actor.Image.URL,
+			}},
+		}
+		if item.ContentHTML == "" {
+			// an item requires either content_html or content_text
+			item.ContentText = item.Title
+		}
+		if !a.Published.IsZero() {
+			item.DatePublished = a.Published.Format(time.RFC3339)
+		}
+		if a.Updated != nil {
+			item.DateModified = a.Updated.Format(time.RFC3339)
+		}
+		if a.Verb != "" {
+			item.Tags = []string{a.Verb}
+		}
+		doc.Items[i] = item
+	}
+	return doc
+}
+
+// WriteJSONFeed writes the page as JSON Feed 1.1 document, see NewJSONFeed
+func WriteJSONFeed(w io.Writer, feed Feed, page Page) error {
+	return json.NewEncoder(w).Encode(NewJSONFeed(feed, page))
+}
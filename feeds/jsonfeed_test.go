@@ -0,0 +1,86 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/chrisport/go-activitystream/activitystream"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+	"time"
+)
+
+func TestJSONFeed(t *testing.T) {
+	feed := createTestFeed()
+	page := createTestPage()
+
+	Convey("Subject: Test rendering a page as JSON Feed", t, func() {
+		Convey("When a full page is rendered", func() {
+			var buf bytes.Buffer
+			err := WriteJSONFeed(&buf, feed, page)
+			So(err, ShouldBeNil)
+
+			var parsed JSONFeed
+			So(json.Unmarshal(buf.Bytes(), &parsed), ShouldBeNil)
+
+			Convey("It should map every activity to an item", func() {
+				So(parsed.Version, ShouldEqual, JSONFeedVersion)
+				So(parsed.FeedURL, ShouldEqual, feed.URL)
+				So(len(parsed.Items), ShouldEqual, 2)
+				item := parsed.Items[0]
+				So(item.Id, ShouldEqual, page.Activities[0].Id)
+				So(item.URL, ShouldEqual, "http://example.com/notes/1")
+				So(item.ContentHTML, ShouldEqual, "<p>Hello</p>")
+				So(item.DatePublished, ShouldEqual, page.Activities[0].Published.Format(time.RFC3339))
+				So(item.Authors[0].Name, ShouldEqual, "Some Person")
+				So(item.Authors[0].Avatar, ShouldEqual, "http://example.com/avatar.png")
+			})
+			Convey("It should link the next page", func() {
+				So(parsed.NextURL, ShouldEqual, fmt.Sprintf("%s?s=2&after=%d", feed.URL, page.Activities[1].Score()))
+			})
+		})
+
+		Convey("When the last page is rendered", func() {
+			page.Activities = page.Activities[:1]
+			parsed := NewJSONFeed(feed, page)
+
+			Convey("It should not link a next page", func() {
+				So(parsed.NextURL, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When an activity has no content", func() {
+			page.Activities[0].Object.Content = ""
+			page.Activities[0].Verb = "follow"
+			parsed := NewJSONFeed(feed, Page{Size: 1, Direction: activitystream.After, Activities: page.Activities[:1]})
+
+			Convey("It should use the title as text content", func() {
+				So(parsed.Items[0].ContentText, ShouldEqual, "Some Person follow Some Note")
+			})
+		})
+
+		Convey("When an activity has been updated", func() {
+			page := createTestPage()
+			updated := page.Activities[0].Published.Add(time.Hour)
+			page.Activities[0].Updated = &updated
+			parsed := NewJSONFeed(feed, page)
+
+			Convey("It should set the modification date", func() {
+				So(parsed.Items[0].DateModified, ShouldEqual, updated.Format(time.RFC3339))
+				So(parsed.Items[1].DateModified, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a page contains a tombstone", func() {
+			page := createTestPage()
+			page.Activities[0] = page.Activities[0].Tombstone(time.Now().UTC())
+			parsed := NewJSONFeed(feed, page)
+
+			Convey("It should leave it out, but still link the next page", func() {
+				So(len(parsed.Items), ShouldEqual, 1)
+				So(parsed.Items[0].Id, ShouldEqual, page.Activities[1].Id)
+				So(parsed.NextURL, ShouldNotBeEmpty)
+			})
+		})
+	})
+}
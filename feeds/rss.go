@@ -0,0 +1,85 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// RSSContentType is the media type of RSS documents
+const RSSContentType = "application/rss+xml"
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	LastBuildDate string     `xml:"lastBuildDate"`
+	AtomLinks     []atomLink `xml:"atom:link"`
+	Items         []rssItem  `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link,omitempty"`
+	Description string  `xml:"description,omitempty"`
+	Category    string  `xml:"category,omitempty"`
+	Guid        rssGuid `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// WriteRSS writes the page as RSS 2.0 feed. RSS has no pagination, therefore the previous and the next page are linked
+// through atom:link elements with rel="prev" and rel="next".
+func WriteRSS(w io.Writer, feed Feed, page Page) error {
+	description := feed.Description
+	if description == "" {
+		description = feed.Title
+	}
+	entries := page.entries()
+	doc := rssDocument{
+		Version: "2.0",
+		Atom:    AtomNamespace,
+		Channel: rssChannel{
+			Title:         feed.Title,
+			Link:          feed.URL,
+			Description:   description,
+			LastBuildDate: updated(page).Format(time.RFC1123Z),
+			AtomLinks:     []atomLink{{Rel: "self", Type: RSSContentType, Href: feed.URL}},
+			Items:         make([]rssItem, len(entries)),
+		},
+	}
+	prev, next := feed.links(page)
+	if prev != "" {
+		doc.Channel.AtomLinks = append(doc.Channel.AtomLinks, atomLink{Rel: "prev", Type: RSSContentType, Href: prev})
+	}
+	if next != "" {
+		doc.Channel.AtomLinks = append(doc.Channel.AtomLinks, atomLink{Rel: "next", Type: RSSContentType, Href: next})
+	}
+
+	for i, a := range entries {
+		doc.Channel.Items[i] = rssItem{
+			Title:       title(a),
+			Link:        a.Object.URL,
+			Description: a.Object.Content,
+			Category:    a.Verb,
+			Guid:        rssGuid{Value: feed.entryId(a)},
+			PubDate:     a.Published.Format(time.RFC1123Z),
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(doc)
+}
@@ -0,0 +1,27 @@
+-- Adds the activity ID ARGV[1] with the score ARGV[2] to the stream KEYS[1] and trims the stream to ARGV[3] activities
+-- (0 keeps all) and the minimum score ARGV[4] ("-inf" keeps all). An empty ARGV[1] only trims the stream.
+-- It returns whether the activity has been added, the status like add_to_streams.lua and the removed IDs, whose
+-- references are released by release_activity.lua as they are stored in other slots of a cluster.
+local added=0
+if ARGV[1]~="" then added=redis.call("ZADD",KEYS[1],ARGV[2],ARGV[1]) end
+local keep=tonumber(ARGV[3])
+local minScore=ARGV[4]
+local status=0
+local removed={}
+if minScore~="-inf" then
+	local ids=redis.call("ZRANGEBYSCORE",KEYS[1],"-inf","("..minScore)
+	if table.getn(ids)>0 then
+		redis.call("ZREMRANGEBYSCORE",KEYS[1],"-inf","("..minScore)
+		for _,id in ipairs(ids) do table.insert(removed,id) end
+	end
+	if ARGV[1]~="" and not redis.call("ZSCORE",KEYS[1],ARGV[1]) then status=2 end
+end
+if keep>0 then
+	local ids=redis.call("ZRANGE",KEYS[1],0,-keep-1)
+	if table.getn(ids)>0 then
+		redis.call("ZREMRANGEBYRANK",KEYS[1],0,-keep-1)
+		for _,id in ipairs(ids) do table.insert(removed,id) end
+	end
+	if ARGV[1]~="" and status==0 and not redis.call("ZSCORE",KEYS[1],ARGV[1]) then status=1 end
+end
+return {added,status,removed}
@@ -0,0 +1,63 @@
+-- Stores the activity ARGV[1] as KEYS[1] unless it exists and adds its ID ARGV[5] to the streams KEYS[3..n]. Afterwards
+-- the streams are trimmed, each with four arguments starting at ARGV[7]: the score of the activity, the number of
+-- activities to keep (0 keeps all), the minimum score ("-inf" keeps all) and whether to delete activities which are no
+-- longer referenced ("1" or "0").
+-- The references of activities which have been stored by this script are counted in KEYS[2], named by the activity
+-- key and the suffix ARGV[3], their history is named by the suffix ARGV[4]. Activity keys are their ID prefixed by
+-- ARGV[6]. A new activity expires after ARGV[2] milliseconds unless it is 0. An empty ARGV[1] only trims the streams.
+-- It returns two numbers for every stream: 0 if the activity has been kept, 1 if it has been trimmed as the stream is
+-- full, 2 if it has been trimmed as it is too old, followed by the number of removed activities.
+local n=table.getn(KEYS)-2
+local function release(ids,gc)
+	for _,id in ipairs(ids) do
+		local key=ARGV[6]..id
+		local refs=key..ARGV[3]
+		if redis.call("EXISTS",refs)==1 and redis.call("DECR",refs)<=0 and gc=="1" then
+			redis.call("DEL",key,refs,key..ARGV[4])
+		end
+	end
+end
+if ARGV[1]~="" then
+	if redis.call("SET",KEYS[1],ARGV[1],"NX") then
+		redis.call("SET",KEYS[2],0)
+		local ttl=tonumber(ARGV[2])
+		if ttl>0 then
+			redis.call("PEXPIRE",KEYS[1],ttl)
+			redis.call("PEXPIRE",KEYS[2],ttl)
+		end
+	end
+	local counted=redis.call("EXISTS",KEYS[2])==1
+	for i=1,n do
+		if redis.call("ZADD",KEYS[i+2],ARGV[4*i+3],ARGV[5])==1 and counted then redis.call("INCR",KEYS[2]) end
+	end
+end
+local result={}
+for i=1,n do
+	local stream=KEYS[i+2]
+	local keep=tonumber(ARGV[4*i+4])
+	local minScore=ARGV[4*i+5]
+	local gc=ARGV[4*i+6]
+	local status=0
+	local removed=0
+	if minScore~="-inf" then
+		local ids=redis.call("ZRANGEBYSCORE",stream,"-inf","("..minScore)
+		if table.getn(ids)>0 then
+			redis.call("ZREMRANGEBYSCORE",stream,"-inf","("..minScore)
+			removed=removed+table.getn(ids)
+			release(ids,gc)
+		end
+		if ARGV[1]~="" and not redis.call("ZSCORE",stream,ARGV[5]) then status=2 end
+	end
+	if keep>0 then
+		local ids=redis.call("ZRANGE",stream,0,-keep-1)
+		if table.getn(ids)>0 then
+			redis.call("ZREMRANGEBYRANK",stream,0,-keep-1)
+			removed=removed+table.getn(ids)
+			release(ids,gc)
+		end
+		if ARGV[1]~="" and status==0 and not redis.call("ZSCORE",stream,ARGV[5]) then status=1 end
+	end
+	table.insert(result,status)
+	table.insert(result,removed)
+end
+return result
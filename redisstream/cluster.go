@@ -0,0 +1,780 @@
+package redisstream
+
+import (
+	"errors"
+	"fmt"
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clusterSlots is the number of hash slots of a Redis Cluster
+const clusterSlots = 16384
+
+// InitCluster initializes the RedisActivityStream for a Redis Cluster dialed via protocol, see InitClusterWithConfig
+func (as *RedisActivityStream) InitCluster(protocol string, addrs ...string) error {
+	return as.InitClusterWithConfig(Config{Protocol: protocol}, addrs...)
+}
+
+// InitClusterWithConfig initializes the RedisActivityStream for a Redis Cluster. The slots of the nodes are discovered
+// through the first reachable address, config.Addr if none is given, and refreshed whenever a node redirects a command.
+// The connections to all nodes are dialed and pooled as configured, Sentinels and a DB other than 0 are not supported.
+// On a cluster the IDs in keys are enclosed in hash tags, e.g. "{ID}-refs", so that all keys of an activity, stream or
+// actor are stored in the same slot. Namespaces and tenant IDs must not contain hash tags. Data written without
+// cluster mode is not found and must be copied to the cluster layout.
+// Commands are routed to the node of their slot and pipelined per node. Streams are resolved client-side, as their
+// activities are stored in other slots. Adding an activity to several streams and blocking are atomic per slot only.
+func (as *RedisActivityStream) InitClusterWithConfig(config Config, addrs ...string) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	if len(config.SentinelAddrs) > 0 || config.DB != 0 {
+		return errors.New("invalid Config, a Redis Cluster supports neither Sentinels nor a DB other than 0")
+	}
+	config = config.withDefaults()
+	if len(addrs) == 0 {
+		addrs = []string{config.Addr}
+	}
+	if as.maxStreamSize == 0 {
+		as.maxStreamSize = activitystream.DefaultMaxStreamSize
+	}
+	cl, err := newCluster(config, addrs...)
+	if err != nil {
+		return err
+	}
+	as.cluster = cl
+	return nil
+}
+
+// cluster knows the node serving every slot of a Redis Cluster and keeps a connection pool per node
+type cluster struct {
+	// config sizes the pools, dial connects to a node
+	config Config
+	dial   func(addr string) (redis.Conn, error)
+	addrs  []string
+
+	mutex sync.RWMutex
+	slots []string
+	pools map[string]*redis.Pool
+}
+
+// newCluster returns a cluster dialing its nodes as configured whose slots have been discovered through one of the
+// addresses
+func newCluster(config Config, addrs ...string) (*cluster, error) {
+	cl := &cluster{
+		config: config,
+		dial:   config.dial,
+		addrs:  addrs,
+		slots:  make([]string, clusterSlots),
+		pools:  make(map[string]*redis.Pool),
+	}
+	return cl, cl.refresh()
+}
+
+// refresh reads the slots through CLUSTER SLOTS from the first node which replies, known nodes are asked first
+func (cl *cluster) refresh() error {
+	err := errors.New("no address of the cluster given")
+	for _, addr := range append(cl.masters(), cl.addrs...) {
+		var slots []string
+		if slots, err = cl.readSlots(addr); err == nil {
+			cl.mutex.Lock()
+			cl.slots = slots
+			cl.mutex.Unlock()
+			return nil
+		}
+	}
+	return mapError(err)
+}
+
+// readSlots returns the address of the master of every slot as replied by the node
+func (cl *cluster) readSlots(addr string) ([]string, error) {
+	c := cl.pool(addr).Get()
+	defer c.Close()
+	ranges, err := redis.Values(c.Do("CLUSTER", "SLOTS"))
+	if err != nil {
+		return nil, err
+	}
+
+	slots := make([]string, clusterSlots)
+	for _, r := range ranges {
+		// start, end, master as host, port and ID, followed by the replicas
+		values, err := redis.Values(r, nil)
+		if err != nil || len(values) < 3 {
+			return nil, errors.New("invalid reply of CLUSTER SLOTS")
+		}
+		start, _ := redis.Int(values[0], nil)
+		end, _ := redis.Int(values[1], nil)
+		master, err := redis.Values(values[2], nil)
+		if err != nil || len(master) < 2 || start < 0 || end >= clusterSlots {
+			return nil, errors.New("invalid reply of CLUSTER SLOTS")
+		}
+		host, _ := redis.String(master[0], nil)
+		port, _ := redis.Int(master[1], nil)
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+			// the node does not know its own address
+			host, _, _ = net.SplitHostPort(addr)
+		}
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+	}
+	return slots, nil
+}
+
+// pool returns the connection pool of the node
+func (cl *cluster) pool(addr string) *redis.Pool {
+	cl.mutex.RLock()
+	p, ok := cl.pools[addr]
+	cl.mutex.RUnlock()
+	if ok {
+		return p
+	}
+
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	if p, ok = cl.pools[addr]; !ok {
+		p = cl.config.pool(func() (redis.Conn, error) {
+			return cl.dial(addr)
+		}, func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		})
+		cl.pools[addr] = p
+	}
+	return p
+}
+
+// node returns the address of the node serving the slot
+func (cl *cluster) node(slot int) string {
+	cl.mutex.RLock()
+	defer cl.mutex.RUnlock()
+	return cl.slots[slot]
+}
+
+// masters returns the addresses of all nodes serving slots
+func (cl *cluster) masters() []string {
+	cl.mutex.RLock()
+	defer cl.mutex.RUnlock()
+	seen := make(map[string]bool)
+	masters := make([]string, 0)
+	for _, addr := range cl.slots {
+		if addr != "" && !seen[addr] {
+			seen[addr] = true
+			masters = append(masters, addr)
+		}
+	}
+	return masters
+}
+
+// moved updates the node of the slot after a redirection and refreshes all slots
+func (cl *cluster) moved(slot int, addr string) {
+	cl.refresh()
+	cl.mutex.Lock()
+	cl.slots[slot] = addr
+	cl.mutex.Unlock()
+}
+
+// conn returns a connection which routes commands to the nodes of the cluster, it must be closed after use
+func (cl *cluster) conn() redis.Conn {
+	return &clusterConn{cluster: cl, conns: make(map[string]redis.Conn)}
+}
+
+// clusterConn routes every command to the node serving the slot of its key, see commandKey. Commands sent to
+// different nodes are pipelined per node, their replies are received in the order the commands have been sent.
+// A transaction is sent to the node of its first command, all its commands must belong to the same node.
+type clusterConn struct {
+	cluster *cluster
+	conns   map[string]redis.Conn
+	// pending are the commands whose replies have not been received
+	pending []clusterCommand
+	// multi is a MULTI which has not been sent, as the node of the transaction is not known yet
+	multi *clusterCommand
+	// tx is the node of the running transaction
+	tx string
+}
+
+type clusterCommand struct {
+	addr string
+	cmd  string
+	args []interface{}
+	// redirect tells whether the command may be redirected, which is not the case within transactions
+	redirect bool
+}
+
+func (c *clusterConn) Send(cmd string, args ...interface{}) error {
+	switch strings.ToUpper(cmd) {
+	case "MULTI":
+		c.multi = &clusterCommand{cmd: cmd, args: args}
+		return nil
+	case "EXEC", "DISCARD":
+		addr := c.tx
+		c.tx = ""
+		if addr == "" {
+			addr = c.anyNode()
+			if err := c.sendMulti(addr); err != nil {
+				return err
+			}
+		}
+		return c.send(clusterCommand{addr: addr, cmd: cmd, args: args})
+	}
+
+	addr := c.anyNode()
+	if key, ok := commandKey(cmd, args); ok {
+		addr = c.cluster.node(keySlot(key))
+	}
+	if err := c.sendMulti(addr); err != nil {
+		return err
+	}
+	if c.tx != "" && c.tx != addr {
+		return errors.New("CROSSSLOT " + cmd + " belongs to another node than the transaction")
+	}
+	return c.send(clusterCommand{addr: addr, cmd: cmd, args: args, redirect: c.tx == ""})
+}
+
+// sendMulti starts the transaction on the node, if it has not been sent
+func (c *clusterConn) sendMulti(addr string) error {
+	if c.multi == nil {
+		return nil
+	}
+	multi := *c.multi
+	multi.addr = addr
+	c.multi = nil
+	c.tx = addr
+	return c.send(multi)
+}
+
+func (c *clusterConn) send(command clusterCommand) error {
+	if command.addr == "" {
+		return activitystream.Wrap(activitystream.ErrBackendUnavailable, errors.New("no node serves the slot of "+command.cmd))
+	}
+	nc, ok := c.conns[command.addr]
+	if !ok {
+		nc = c.cluster.pool(command.addr).Get()
+		c.conns[command.addr] = nc
+	}
+	if err := nc.Send(command.cmd, command.args...); err != nil {
+		return err
+	}
+	c.pending = append(c.pending, command)
+	return nil
+}
+
+// anyNode returns the node of keyless commands
+func (c *clusterConn) anyNode() string {
+	for addr := range c.conns {
+		return addr
+	}
+	if masters := c.cluster.masters(); len(masters) > 0 {
+		return masters[0]
+	}
+	return ""
+}
+
+func (c *clusterConn) Flush() error {
+	for _, nc := range c.conns {
+		if err := nc.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Receive returns the reply of the oldest pending command. Commands redirected by MOVED or ASK are repeated on the
+// node they have been redirected to.
+func (c *clusterConn) Receive() (interface{}, error) {
+	if len(c.pending) == 0 {
+		return nil, errors.New("redisstream: no pending command")
+	}
+	command := c.pending[0]
+	c.pending = c.pending[1:]
+	reply, err := c.conns[command.addr].Receive()
+
+	redirection, ok := err.(redis.Error)
+	if !ok || !command.redirect {
+		return reply, err
+	}
+	fields := strings.Fields(redirection.Error())
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return reply, err
+	}
+	slot, _ := strconv.Atoi(fields[1])
+	rc := c.cluster.pool(fields[2]).Get()
+	defer rc.Close()
+	if fields[0] == "ASK" {
+		rc.Send("ASKING")
+	} else {
+		c.cluster.moved(slot, fields[2])
+	}
+	return rc.Do(command.cmd, command.args...)
+}
+
+// Do sends the command and receives the replies of all pending commands, returning the last. Like the connections of
+// redigo, an empty command returns the replies of all pending commands. Other than those, it also returns the first
+// error replied.
+func (c *clusterConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if cmd != "" {
+		if err := c.Send(cmd, args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, 0, len(c.pending))
+	var err error
+	for len(c.pending) > 0 {
+		reply, e := c.Receive()
+		if _, ok := e.(redis.Error); e != nil && !ok {
+			return nil, e
+		}
+		if e != nil {
+			reply = e
+			if err == nil {
+				err = e
+			}
+		}
+		replies = append(replies, reply)
+	}
+	if cmd == "" {
+		return replies, err
+	}
+	if _, ok := replies[len(replies)-1].(redis.Error); ok {
+		return nil, err
+	}
+	return replies[len(replies)-1], err
+}
+
+func (c *clusterConn) Err() error {
+	for _, nc := range c.conns {
+		if err := nc.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *clusterConn) Close() error {
+	var err error
+	for _, nc := range c.conns {
+		if e := nc.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// commandKey returns the key which decides on the node of the command and false if the command has no key. This is
+// the first key of scripts and the first argument of all other commands except the keyless ones.
+func commandKey(cmd string, args []interface{}) (string, bool) {
+	switch strings.ToUpper(cmd) {
+	case "EVAL", "EVALSHA":
+		if len(args) < 3 || fmt.Sprint(args[1]) == "0" {
+			return "", false
+		}
+		return keyString(args[2]), true
+	case "PING", "SCAN", "MULTI", "EXEC", "DISCARD", "ASKING", "CLUSTER", "SCRIPT", "INFO", "AUTH", "SELECT":
+		return "", false
+	}
+	if len(args) == 0 {
+		return "", false
+	}
+	return keyString(args[0]), true
+}
+
+func keyString(arg interface{}) string {
+	switch key := arg.(type) {
+	case string:
+		return key
+	case []byte:
+		return string(key)
+	}
+	return fmt.Sprint(arg)
+}
+
+// keySlot returns the hash slot of the key, which is the CRC16 of its hash tag, or of the whole key if it has none
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % clusterSlots)
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum used by Redis Cluster
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// tag encloses the ID in a hash tag on a cluster, so that all keys derived from it are stored in the same slot
+func (as *RedisActivityStream) tag(id string) string {
+	if as.cluster == nil {
+		return id
+	}
+	return "{" + id + "}"
+}
+
+// untag reverts tag
+func (as *RedisActivityStream) untag(name string) string {
+	if as.cluster == nil || !strings.HasPrefix(name, "{") || !strings.HasSuffix(name, "}") {
+		return name
+	}
+	return name[1 : len(name)-1]
+}
+
+// slotGroups returns the indexes of the keys grouped by their slot on a cluster, otherwise a single group of all keys
+func (as *RedisActivityStream) slotGroups(keys []string) [][]int {
+	if len(keys) == 0 {
+		return nil
+	}
+	if as.cluster == nil {
+		group := make([]int, len(keys))
+		for i := range keys {
+			group[i] = i
+		}
+		return [][]int{group}
+	}
+	groups := make([][]int, 0)
+	bySlot := make(map[int]int)
+	for i, key := range keys {
+		slot := keySlot(key)
+		g, ok := bySlot[slot]
+		if !ok {
+			g = len(groups)
+			bySlot[slot] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
+
+// mget returns the values of the keys like MGET. On a cluster one MGET is sent per slot, pipelined per node.
+func (as *RedisActivityStream) mget(c redis.Conn, keys []string) ([]interface{}, error) {
+	groups := as.slotGroups(keys)
+	for _, group := range groups {
+		args := make([]interface{}, len(group))
+		for i, k := range group {
+			args[i] = keys[k]
+		}
+		c.Send("MGET", args...)
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(keys))
+	for _, group := range groups {
+		reply, err := redis.Values(c.Receive())
+		if err != nil {
+			return nil, err
+		}
+		for i, k := range group {
+			values[k] = reply[i]
+		}
+	}
+	return values, nil
+}
+
+// multi starts a transaction, which is completed by exec. On a cluster the commands are only pipelined, as their keys
+// may belong to different nodes.
+func (as *RedisActivityStream) multi(c redis.Conn) {
+	if as.cluster == nil {
+		c.Send("MULTI")
+	}
+}
+
+// exec completes a transaction started by multi
+func (as *RedisActivityStream) exec(c redis.Conn) error {
+	if as.cluster == nil {
+		_, err := c.Do("EXEC")
+		return err
+	}
+	_, err := c.Do("")
+	return err
+}
+
+// scan calls f with all keys of the tenant in batches, on a cluster the keys of every node are scanned
+func (as *RedisActivityStream) scan(count int, f func(keys []string) error) error {
+	if as.cluster == nil {
+		c := as.conn()
+		defer c.Close()
+		return as.scanNode(c, count, nil, f)
+	}
+	for _, addr := range as.cluster.masters() {
+		node := addr
+		c := conn{as.cluster.pool(addr).Get()}
+		// while a slot migrates, its keys may be found on two nodes
+		err := as.scanNode(c, count, func(key string) bool { return as.cluster.node(keySlot(key)) == node }, f)
+		c.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanNode scans the keys of a single node which are accepted by filter, nil accepts all
+func (as *RedisActivityStream) scanNode(c redis.Conn, count int, filter func(key string) bool, f func(keys []string) error) error {
+	cursor := "0"
+	for {
+		reply, err := redis.Values(c.Do("SCAN", as.scanArgs(cursor, count)...))
+		if err != nil {
+			return err
+		}
+		var keys []string
+		if _, err := redis.Scan(reply, &cursor, &keys); err != nil {
+			return err
+		}
+		if filter != nil {
+			accepted := keys[:0]
+			for _, key := range keys {
+				if filter(key) {
+					accepted = append(accepted, key)
+				}
+			}
+			keys = accepted
+		}
+		if len(keys) > 0 {
+			if err := f(keys); err != nil {
+				return err
+			}
+		}
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
+
+// resolveStreamOnCluster resolves the stream keys[0] like the resolution scripts, but client-side, as its activities
+// and the sets of the viewer are stored in other slots. It returns the pinned and the other activities as replied by
+// the scripts, the latter in the order of the stream.
+func (as *RedisActivityStream) resolveStreamOnCluster(keys []string, limit int, pivotTime int, afterNotBefore activitystream.Direction, viewer ...interface{}) ([]interface{}, []interface{}, error) {
+	c := as.conn()
+	defer c.Close()
+
+	fetch := func(offset, count int) ([]string, error) {
+		switch {
+		case pivotTime == 0 && count < 0:
+			return redis.Strings(c.Do("ZREVRANGE", keys[0], offset, -1))
+		case pivotTime == 0:
+			return redis.Strings(c.Do("ZREVRANGE", keys[0], offset, offset+count-1))
+		case afterNotBefore == activitystream.After:
+			return redis.Strings(c.Do("ZREVRANGEBYSCORE", keys[0], pivotTime, "-inf", "LIMIT", offset+1, count))
+		default:
+			return redis.Strings(c.Do("ZRANGEBYSCORE", keys[0], pivotTime, "+inf", "LIMIT", offset+1, count))
+		}
+	}
+	pins, err := redis.Strings(c.Do("ZREVRANGE", keys[1], 0, -1))
+	if err != nil {
+		return nil, nil, err
+	}
+	isPinned := make(map[string]bool)
+	for _, id := range pins {
+		isPinned[id] = true
+	}
+	if pivotTime != 0 {
+		pins = nil
+	}
+
+	pinned, err := as.resolveVisible(c, pins, keys[2:], viewer)
+	if err != nil {
+		return nil, nil, err
+	}
+	result := make([]interface{}, 0)
+	offset := 0
+	for {
+		count := -1
+		if limit > 0 {
+			count = limit - len(result)
+		}
+		ids, err := fetch(offset, count)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		offset += len(ids)
+		unpinned := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if !isPinned[id] {
+				unpinned = append(unpinned, id)
+			}
+		}
+		visible, err := as.resolveVisible(c, unpinned, keys[2:], viewer)
+		if err != nil {
+			return nil, nil, err
+		}
+		result = append(result, visible...)
+		if count < 0 || len(result) >= limit || len(ids) < count {
+			break
+		}
+	}
+	return pinned, result, nil
+}
+
+// resolveVisible returns the stored activities of the IDs. If a viewer is given, activities of actors in one of the
+// moderation sets and activities which are not visible to the viewer are left out, see Activity.IsVisibleTo.
+func (as *RedisActivityStream) resolveVisible(c redis.Conn, ids []string, moderation []string, viewer []interface{}) ([]interface{}, error) {
+	keys := make([]string, len(ids))
+	for i := range ids {
+		keys[i] = as.activityKey(ids[i])
+	}
+	values, err := as.mget(c, keys)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, len(values))
+	if len(viewer) == 0 {
+		for _, value := range values {
+			if value != nil {
+				result = append(result, value)
+			}
+		}
+		return result, nil
+	}
+
+	viewerId := keyString(viewer[0])
+	activities := make([]*activitystream.Activity, len(values))
+	actors := make([]string, 0)
+	collections := make([]string, 0)
+	seen := make(map[string]bool)
+	for i, value := range values {
+		activity, err := parseActivityFromResponse(value, nil)
+		if err != nil {
+			continue
+		}
+		activities[i] = &activity
+		if id := activity.Actor.Id; id != viewerId && !seen["actor "+id] {
+			seen["actor "+id] = true
+			actors = append(actors, id)
+		}
+		for _, id := range activity.Audience() {
+			if id != viewerId && !seen["collection "+id] {
+				seen["collection "+id] = true
+				collections = append(collections, id)
+			}
+		}
+	}
+
+	for _, actor := range actors {
+		for _, key := range moderation {
+			c.Send("SISMEMBER", key, actor)
+		}
+	}
+	for _, collection := range collections {
+		c.Send("SISMEMBER", as.collectionKey(collection), viewerId)
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	hidden := make(map[string]bool)
+	for _, actor := range actors {
+		for range moderation {
+			member, err := redis.Bool(c.Receive())
+			if err != nil {
+				return nil, err
+			}
+			hidden[actor] = hidden[actor] || member
+		}
+	}
+	isMember := make(map[string]bool)
+	for _, collection := range collections {
+		member, err := redis.Bool(c.Receive())
+		if err != nil {
+			return nil, err
+		}
+		isMember[collection] = member
+	}
+
+	for i, activity := range activities {
+		if activity == nil || hidden[activity.Actor.Id] {
+			continue
+		}
+		if activity.IsVisibleTo(viewerId, func(id string) bool { return isMember[id] }) {
+			result = append(result, values[i])
+		}
+	}
+	return result, nil
+}
+
+// addToStreamsOnCluster adds the activity to the streams like add_to_streams.lua, but with one script per slot. A nil
+// activity only trims the streams. It returns the status and the number of removed activities for every stream.
+func (as *RedisActivityStream) addToStreamsOnCluster(activity activitystream.Activity, a []byte, streamIds []string) ([]int, error) {
+	c := as.conn()
+	defer c.Close()
+
+	counted := false
+	if a != nil {
+		var err error
+		ttl := int64(as.activityTTL(streamIds) / time.Millisecond)
+		counted, err = redis.Bool(scriptStoreActivity.Do(c, 2, as.activityKey(activity.Id), as.referencesKey(activity.Id), a, ttl))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	gc := make([]interface{}, len(streamIds))
+	calls := make([]scriptCall, len(streamIds))
+	for i, streamId := range streamIds {
+		trim := as.trimArgs(streamId)
+		gc[i] = trim[2]
+		var id interface{} = ""
+		var score interface{} = 0
+		if a != nil {
+			id, score = activity.Id, as.score(streamId, activity)
+		}
+		calls[i] = scriptCall{scriptAddToStream, []interface{}{1, as.streamKey(streamId), id, score, trim[0], trim[1]}}
+	}
+	replies, err := evalPipelined(c, calls)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]int, 0, 2*len(streamIds))
+	removed := make([][]string, len(streamIds))
+	added := 0
+	for i := range streamIds {
+		reply, err := redis.Values(replies[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		var n, s int
+		if _, err := redis.Scan(reply, &n, &s, &removed[i]); err != nil {
+			return nil, err
+		}
+		added += n
+		status = append(status, s, len(removed[i]))
+	}
+
+	// the references are counted before they are released, as the activity may have been removed right away
+	if counted && added > 0 {
+		if _, err := c.Do("INCRBY", as.referencesKey(activity.Id), added); err != nil {
+			return nil, err
+		}
+	}
+	calls = calls[:0]
+	for i := range streamIds {
+		for _, id := range removed[i] {
+			calls = append(calls, scriptCall{scriptReleaseActivity, []interface{}{3, as.activityKey(id), as.referencesKey(id), as.historyKey(id), gc[i]}})
+		}
+	}
+	_, err = evalPipelined(c, calls)
+	return status, err
+}
@@ -0,0 +1,311 @@
+package redisstream
+
+import (
+	"errors"
+	"fmt"
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"sync"
+	testing "testing"
+	"time"
+)
+
+func TestKeySlot(t *testing.T) {
+	Convey("Subject: Test hash slots of keys", t, func() {
+		Convey("It should compute the CRC16 of the key", func() {
+			So(keySlot("123456789"), ShouldEqual, 12739)
+			So(keySlot("foo"), ShouldEqual, 12182)
+			So(keySlot("bar"), ShouldEqual, 5061)
+		})
+		Convey("It should only hash the first hash tag", func() {
+			So(keySlot("{user1000}.following"), ShouldEqual, keySlot("{user1000}.followers"))
+			So(keySlot("as:act:{ID}-refs"), ShouldEqual, keySlot("ID"))
+			So(keySlot("foo{bar}{zap}"), ShouldEqual, keySlot("bar"))
+			So(keySlot("foo{{bar}}zap"), ShouldEqual, keySlot("{bar"))
+		})
+		Convey("It should hash the whole key if the hash tag is empty", func() {
+			So(keySlot("foo{}{bar}"), ShouldEqual, int(crc16("foo{}{bar}")%clusterSlots))
+		})
+	})
+	Convey("Subject: Test the keys commands are routed by", t, func() {
+		Convey("It should take the first key of scripts", func() {
+			key, ok := commandKey("eval", []interface{}{"return 1", 2, "KEY_A", "KEY_B", "ARG"})
+			So(ok, ShouldBeTrue)
+			So(key, ShouldEqual, "KEY_A")
+			_, ok = commandKey("EVAL", []interface{}{"return 1", 0, "ARG"})
+			So(ok, ShouldBeFalse)
+		})
+		Convey("It should take the first argument of other commands", func() {
+			key, ok := commandKey("GET", []interface{}{[]byte("KEY_A")})
+			So(ok, ShouldBeTrue)
+			So(key, ShouldEqual, "KEY_A")
+			_, ok = commandKey("SCAN", []interface{}{"0"})
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+// slotCheckingConn records every command whose keys belong to several slots or to a slot of another node
+type slotCheckingConn struct {
+	redis.Conn
+	addr    string
+	cluster *cluster
+	report  func(violation string)
+}
+
+func (c slotCheckingConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	c.check(cmd, args)
+	return c.Conn.Do(cmd, args...)
+}
+
+func (c slotCheckingConn) Send(cmd string, args ...interface{}) error {
+	c.check(cmd, args)
+	return c.Conn.Send(cmd, args...)
+}
+
+func (c slotCheckingConn) check(cmd string, args []interface{}) {
+	var keys []interface{}
+	switch strings.ToUpper(cmd) {
+	case "", "PING", "SCAN", "MULTI", "EXEC", "CLUSTER":
+	case "EVAL", "EVALSHA":
+		n, _ := args[1].(int)
+		keys = args[2 : 2+n]
+	case "MGET", "DEL", "EXISTS", "RENAMENX":
+		keys = args
+	default:
+		keys = args[:1]
+	}
+	for _, key := range keys {
+		slot := keySlot(keyString(key))
+		if slot != keySlot(keyString(keys[0])) {
+			c.report(fmt.Sprintf("%s %v: keys of several slots", cmd, keys))
+		}
+		if node := c.cluster.node(slot); node != c.addr {
+			c.report(fmt.Sprintf("%s %v: sent to %s instead of %s", cmd, key, c.addr, node))
+		}
+	}
+}
+
+func TestCluster(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	// two nodes of a fake cluster, both served by the same Redis
+	nodes := []string{"127.0.0.1" + address, "localhost" + address}
+	var mutex sync.Mutex
+	violations := make([]string, 0)
+	cl := &cluster{config: Config{}.withDefaults(), slots: make([]string, clusterSlots), pools: make(map[string]*redis.Pool)}
+	for slot := range cl.slots {
+		cl.slots[slot] = nodes[slot*len(nodes)/clusterSlots]
+	}
+	cl.dial = func(addr string) (redis.Conn, error) {
+		c, err := redis.Dial(protocol, addr)
+		if err != nil {
+			return nil, err
+		}
+		return slotCheckingConn{Conn: c, addr: addr, cluster: cl, report: func(violation string) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			violations = append(violations, violation)
+		}}, nil
+	}
+	root := &RedisActivityStream{maxStreamSize: activitystream.DefaultMaxStreamSize, cluster: cl}
+	asUnderTest := root.forTenant("CLUSTER_TEST")
+
+	testStreamIDs := []string{"CLUSTER_STREAM_A", "CLUSTER_STREAM_B", "CLUSTER_STREAM_C"}
+	viewerID := "CLUSTER_VIEWER_ID"
+	followers := "CLUSTER_FOLLOWERS_ID"
+	testActivities := make([]activitystream.Activity, 4)
+	start := time.Now().UTC()
+	for i := range testActivities {
+		testActivities[i] = createTestActivity()
+		testActivities[i].Published = start.Add(time.Duration(i) * time.Millisecond)
+	}
+	testActivities[1].Actor.Id = "CLUSTER_MUTED_ACTOR_ID"
+	testActivities[2].To = []string{followers}
+	testActivities[2].Bcc = []string{"CLUSTER_SECRET_ID"}
+	testActivities[3].Actor.Id = "CLUSTER_BLOCKED_ACTOR_ID"
+	cleanUp := func() {
+		if _, err := root.DeleteTenant("CLUSTER_TEST"); err != nil {
+			panic(err)
+		}
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test cluster mode", t, func() {
+		cleanUp()
+		asUnderTest.SetRetentionPolicies()
+		for i := range testActivities {
+			So(asUnderTest.AddToStreams(testActivities[i], testStreamIDs...), ShouldBeEmpty)
+		}
+		So(asUnderTest.Mute(viewerID, testActivities[1].Actor.Id), ShouldBeNil)
+		So(asUnderTest.Block(viewerID, testActivities[3].Actor.Id), ShouldBeNil)
+		So(asUnderTest.AddToCollection(followers, viewerID), ShouldBeNil)
+
+		Convey("When the cluster is discovered", func() {
+			single := RedisActivityStream{}
+			err := single.InitCluster(protocol, address)
+			So(err, ShouldBeNil)
+
+			Convey("It should route all slots to the node", func() {
+				So(len(single.cluster.masters()), ShouldEqual, 1)
+				activity, err := single.ForTenant("CLUSTER_TEST").Get(testActivities[0].Id)
+				So(err, ShouldBeNil)
+				So(activity.Id, ShouldEqual, testActivities[0].Id)
+			})
+		})
+		Convey("When the cluster is discovered with a Config", func() {
+			configured := RedisActivityStream{}
+			err := configured.InitClusterWithConfig(Config{Protocol: protocol, Addr: address, MaxIdle: 7, DialTimeout: time.Second})
+
+			Convey("It should dial and pool the connections to the nodes as configured", func() {
+				So(err, ShouldBeNil)
+				So(configured.cluster.pool(configured.cluster.masters()[0]).MaxIdle, ShouldEqual, 7)
+				activity, err := configured.ForTenant("CLUSTER_TEST").Get(testActivities[0].Id)
+				So(err, ShouldBeNil)
+				So(activity.Id, ShouldEqual, testActivities[0].Id)
+			})
+			Convey("It should authenticate the connections", func() {
+				err := (&RedisActivityStream{}).InitClusterWithConfig(Config{Protocol: protocol, Password: "CLUSTER_WRONG_PASSWORD"}, address)
+				So(err, ShouldNotBeNil)
+			})
+			Convey("It should reject Sentinels and databases", func() {
+				err := (&RedisActivityStream{}).InitClusterWithConfig(Config{DB: 1}, address)
+				So(err, ShouldNotBeNil)
+			})
+		})
+		Convey("When streams are read", func() {
+			Convey("It should resolve the activities of all slots", func() {
+				for _, streamID := range testStreamIDs {
+					stream, err := asUnderTest.GetStream(streamID, 10, 0, activitystream.After)
+					So(err, ShouldBeNil)
+					So(len(stream), ShouldEqual, 4)
+					for i := range stream {
+						So(stream[i].Id, ShouldEqual, testActivities[3-i].Id)
+					}
+				}
+				stream, err := asUnderTest.GetStream(testStreamIDs[0], 2, int(activitystream.MakeTimestamp(testActivities[2].Published)), activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 2)
+				So(stream[0].Id, ShouldEqual, testActivities[1].Id)
+			})
+			Convey("It should leave out activities which are not visible to the viewer", func() {
+				stream, err := asUnderTest.GetStreamForViewer(testStreamIDs[0], viewerID, 10, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 2)
+				So(stream[0].Id, ShouldEqual, testActivities[2].Id)
+				So(stream[0].Bcc, ShouldBeEmpty)
+				So(stream[1].Id, ShouldEqual, testActivities[0].Id)
+
+				stream, err = asUnderTest.GetStreamForViewer(testStreamIDs[0], "", 10, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 3)
+				So(stream[1].Id, ShouldEqual, testActivities[1].Id)
+			})
+			Convey("It should return pinned activities first", func() {
+				So(asUnderTest.Pin(testStreamIDs[0], testActivities[0].Id), ShouldBeNil)
+				stream, err := asUnderTest.GetStream(testStreamIDs[0], 2, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 3)
+				So(stream[0].Id, ShouldEqual, testActivities[0].Id)
+				So(stream[0].Pinned, ShouldBeTrue)
+			})
+		})
+		Convey("When activities are read in bulk", func() {
+			asUnderTest.SetUseMGET(true)
+			results, err := asUnderTest.BulkGetResults(testActivities[0].Id, "CLUSTER_MISSING_ID", testActivities[3].Id, testActivities[2].Id)
+			So(err, ShouldBeNil)
+
+			Convey("It should read the activities of all slots", func() {
+				So(results[0].Activity.Id, ShouldEqual, testActivities[0].Id)
+				So(results[1].Err, ShouldEqual, activitystream.ErrNotFound)
+				So(results[2].Activity.Id, ShouldEqual, testActivities[3].Id)
+				So(results[3].Activity.Id, ShouldEqual, testActivities[2].Id)
+			})
+		})
+		Convey("When activities are updated and deleted", func() {
+			So(asUnderTest.Update(testActivities[0], ""), ShouldBeNil)
+			So(asUnderTest.Delete(testActivities[1].Id), ShouldBeNil)
+
+			Convey("It should keep history and tombstone in the slot of the activity", func() {
+				history, err := asUnderTest.History(testActivities[0].Id)
+				So(err, ShouldBeNil)
+				So(len(history), ShouldEqual, 1)
+				activity, err := asUnderTest.Get(testActivities[1].Id)
+				So(err, ShouldBeNil)
+				So(activity.IsTombstone(), ShouldBeTrue)
+			})
+		})
+		Convey("When streams are trimmed", func() {
+			asUnderTest.SetRetentionPolicies(activitystream.RetentionPolicy{Pattern: "CLUSTER_STREAM_?", MaxSize: 2, CollectGarbage: true})
+			trimmed := 0
+			for _, streamID := range testStreamIDs {
+				n, err := asUnderTest.Trim(streamID)
+				So(err, ShouldBeNil)
+				trimmed += n
+			}
+
+			Convey("It should release and collect the activities in their slots", func() {
+				So(trimmed, ShouldEqual, 6)
+				_, err := asUnderTest.Get(testActivities[0].Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+				_, err = asUnderTest.Get(testActivities[2].Id)
+				So(err, ShouldBeNil)
+			})
+			Convey("It should report activities trimmed right away", func() {
+				old := createTestActivity()
+				old.Published = start.Add(-time.Hour)
+				errs := asUnderTest.AddToStreams(old, testStreamIDs[0])
+				So(len(errs), ShouldEqual, 1)
+				So(errors.Is(errs[0], activitystream.ErrStreamFull), ShouldBeTrue)
+				_, err := asUnderTest.Get(old.Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+			})
+		})
+		Convey("When garbage is collected", func() {
+			orphaned := createTestActivity()
+			orphaned.Published = start.Add(-time.Hour)
+			So(asUnderTest.Store(orphaned), ShouldBeNil)
+			report, err := asUnderTest.CollectGarbage(GCOptions{MinAge: time.Minute, BatchSize: 3})
+			So(err, ShouldBeNil)
+
+			Convey("It should scan every node once", func() {
+				So(report.Scanned, ShouldEqual, 5)
+				So(report.Orphaned, ShouldResemble, []string{orphaned.Id})
+				So(report.Deleted, ShouldEqual, 1)
+			})
+		})
+		Convey("When keys are migrated", func() {
+			renames, err := asUnderTest.MigrateIds(Namespace{}, NewNamespace("as"), MigrationIds{Streams: testStreamIDs, Actors: []string{viewerID}}, false)
+			So(err, ShouldBeNil)
+			migrated := *asUnderTest
+			migrated.SetNamespace(NewNamespace("as"))
+
+			Convey("It should keep the keys in their slots", func() {
+				So(renames, ShouldNotBeEmpty)
+				stream, err := migrated.GetStreamForViewer(testStreamIDs[0], viewerID, 10, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 2)
+			})
+		})
+		Convey("When the tenant is deleted", func() {
+			deleted, err := root.DeleteTenant("CLUSTER_TEST")
+			So(err, ShouldBeNil)
+
+			Convey("It should delete the keys of all nodes", func() {
+				So(deleted, ShouldBeGreaterThan, 0)
+				keys, err := redis.Strings(asUnderTest.execute("KEYS", "tenant:CLUSTER_TEST:*"))
+				So(err, ShouldBeNil)
+				So(keys, ShouldBeEmpty)
+			})
+		})
+
+		Convey("It should send no command to the wrong node", func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			So(violations, ShouldBeEmpty)
+		})
+	})
+}
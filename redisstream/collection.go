@@ -0,0 +1,42 @@
+package redisstream
+
+// collectionSuffix is appended to the ID of a collection to get the key of the set of its members
+const collectionSuffix = "-members"
+
+// collectionKey returns the key of the set of member IDs of the collection
+func (as *RedisActivityStream) collectionKey(collectionId string) string {
+	return as.actorKey(collectionId) + collectionSuffix
+}
+
+// AddToCollection adds members to a collection, e.g. the followers of an actor. Activities addressed to the collection
+// are visible to its members when reading through GetStreamForViewer.
+func (as *RedisActivityStream) AddToCollection(collectionId string, memberIds ...string) error {
+	if len(memberIds) == 0 {
+		return nil
+	}
+	if err := as.checkKeys(as.collectionKey(collectionId)); err != nil {
+		return err
+	}
+	args := []interface{}{as.collectionKey(collectionId)}
+	for i := range memberIds {
+		args = append(args, memberIds[i])
+	}
+	_, err := as.execute("SADD", args...)
+	return err
+}
+
+// RemoveFromCollection removes members from a collection.
+func (as *RedisActivityStream) RemoveFromCollection(collectionId string, memberIds ...string) error {
+	if len(memberIds) == 0 {
+		return nil
+	}
+	if err := as.checkKeys(as.collectionKey(collectionId)); err != nil {
+		return err
+	}
+	args := []interface{}{as.collectionKey(collectionId)}
+	for i := range memberIds {
+		args = append(args, memberIds[i])
+	}
+	_, err := as.execute("SREM", args...)
+	return err
+}
@@ -0,0 +1,116 @@
+package redisstream
+
+import (
+	"github.com/chrisport/go-activitystream/activitystream"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+	"time"
+)
+
+func TestAudience(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	asUnderTest := RedisActivityStream{}
+	asUnderTest.Init(protocol, address)
+
+	testStreamID := "AUDIENCE_STREAM_ID"
+	followers := "ACTOR_ID-followers"
+	start := time.Now().UTC()
+	publicActivity := createTestActivity()
+	publicActivity.Published = start
+	publicActivity.To = []string{activitystream.PublicAudience}
+	followersActivity := createTestActivity()
+	followersActivity.Published = start.Add(time.Millisecond)
+	followersActivity.To = []string{followers}
+	directActivity := createTestActivity()
+	directActivity.Published = start.Add(2 * time.Millisecond)
+	directActivity.To = []string{"FRIEND_ID"}
+	directActivity.Cc = []string{"COPIED_ID"}
+	directActivity.Bcc = []string{"SECRET_ID"}
+	cleanUp := func() {
+		removeFromRedis(testStreamID, asUnderTest.collectionKey(followers), publicActivity.Id, followersActivity.Id, directActivity.Id,
+			"AUDIENCE_NOT_AN_ACTIVITY")
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test visibility by audience on GetStreamForViewer", t, func() {
+		cleanUp()
+		So(asUnderTest.AddToCollection(followers, "FOLLOWER_ID", "FRIEND_ID"), ShouldBeNil)
+		for _, a := range []activitystream.Activity{publicActivity, followersActivity, directActivity} {
+			So(asUnderTest.AddToStreams(a, testStreamID), ShouldBeEmpty)
+		}
+
+		Convey("When an anonymous viewer reads the stream", func() {
+			stream, err := asUnderTest.GetStreamForViewer(testStreamID, "", 0, 0, activitystream.After)
+			So(err, ShouldBeNil)
+
+			Convey("It should only return public activities", func() {
+				So(len(stream), ShouldEqual, 1)
+				So(stream[0].Id, ShouldEqual, publicActivity.Id)
+			})
+		})
+		Convey("When the stream contains an entry which is no activity", func() {
+			_, err := asUnderTest.execute("SET", "AUDIENCE_NOT_AN_ACTIVITY", "not json")
+			So(err, ShouldBeNil)
+			_, err = asUnderTest.execute("ZADD", testStreamID, 0, "AUDIENCE_NOT_AN_ACTIVITY")
+			So(err, ShouldBeNil)
+			stream, err := asUnderTest.GetStreamForViewer(testStreamID, "", 0, 0, activitystream.After)
+
+			Convey("It should leave it out like GetStream", func() {
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 1)
+				So(stream[0].Id, ShouldEqual, publicActivity.Id)
+			})
+		})
+		Convey("When a follower reads the stream", func() {
+			stream, err := asUnderTest.GetStreamForViewer(testStreamID, "FOLLOWER_ID", 1, 0, activitystream.After)
+			So(err, ShouldBeNil)
+
+			Convey("It should return the activities addressed to the followers and fill the page", func() {
+				So(len(stream), ShouldEqual, 1)
+				So(stream[0].Id, ShouldEqual, followersActivity.Id)
+			})
+		})
+		Convey("When the addressed friend reads the stream", func() {
+			stream, err := asUnderTest.GetStreamForViewer(testStreamID, "FRIEND_ID", 0, 0, activitystream.After)
+			So(err, ShouldBeNil)
+
+			Convey("It should return all activities", func() {
+				So(len(stream), ShouldEqual, 3)
+			})
+		})
+		Convey("When a follower is removed from the collection", func() {
+			So(asUnderTest.RemoveFromCollection(followers, "FOLLOWER_ID"), ShouldBeNil)
+			stream, err := asUnderTest.GetStreamForViewer(testStreamID, "FOLLOWER_ID", 0, 0, activitystream.After)
+			So(err, ShouldBeNil)
+
+			Convey("It should not return the activities addressed to the followers anymore", func() {
+				So(len(stream), ShouldEqual, 1)
+				So(stream[0].Id, ShouldEqual, publicActivity.Id)
+			})
+		})
+		Convey("When the actor reads the stream", func() {
+			stream, err := asUnderTest.GetStreamForViewer(testStreamID, "ACTOR_ID", 0, 0, activitystream.After)
+			So(err, ShouldBeNil)
+
+			Convey("It should return all own activities", func() {
+				So(len(stream), ShouldEqual, 3)
+			})
+			Convey("It should return the blind recipients", func() {
+				So(stream[0].Id, ShouldEqual, directActivity.Id)
+				So(stream[0].Bcc, ShouldResemble, directActivity.Bcc)
+			})
+		})
+		Convey("When a viewer in Cc reads the stream", func() {
+			stream, err := asUnderTest.GetStreamForViewer(testStreamID, "COPIED_ID", 0, 0, activitystream.After)
+			So(err, ShouldBeNil)
+
+			Convey("It should not return the blind recipients", func() {
+				So(len(stream), ShouldEqual, 2)
+				So(stream[0].Id, ShouldEqual, directActivity.Id)
+				So(stream[0].Bcc, ShouldBeEmpty)
+			})
+		})
+	})
+}
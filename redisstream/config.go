@@ -0,0 +1,259 @@
+package redisstream
+
+import (
+	"crypto/tls"
+	"errors"
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// RedisDefaultMaxIdle is the default maximum number of idle connections of the pool
+	RedisDefaultMaxIdle = 3
+	// RedisDefaultIdleTimeout is the default duration after which idle connections of the pool are closed
+	RedisDefaultIdleTimeout = 180 * time.Second
+)
+
+// Config configures the connection of a RedisActivityStream to Redis. Zero values are replaced by the defaults.
+type Config struct {
+	// Protocol and Addr are used to dial Redis, "tcp" and ":6379" by default. Addr is ignored if the master is
+	// discovered through Sentinel.
+	Protocol string
+	Addr     string
+
+	// SentinelAddrs are the addresses of the Sentinels monitoring the master MasterName. The address of the master is
+	// asked for whenever a connection is dialed, the Sentinels are asked in turn until one of them replies.
+	// Connections are checked to still be connected to the master when taken from the pool, so that connections to a
+	// master which was demoted on failover are dropped.
+	SentinelAddrs []string
+	MasterName    string
+	// SentinelPassword authenticates the connections to the Sentinels
+	SentinelPassword string
+
+	// Password authenticates the connections, together with Username for Redis ACLs
+	Username string
+	Password string
+	// DB is the database selected by the connections
+	DB int
+
+	// TLS enables TLS, configured by TLSConfig if it is not nil
+	TLS       bool
+	TLSConfig *tls.Config
+
+	// DialTimeout limits connecting to Redis and the Sentinels, ReadTimeout and WriteTimeout every command.
+	// 0 waits without limit.
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// MaxIdle, MaxActive, IdleTimeout and Wait configure the connection pool like the fields of redis.Pool.
+	// MaxIdle is RedisDefaultMaxIdle and IdleTimeout RedisDefaultIdleTimeout by default, MaxActive 0 does not limit the
+	// number of connections.
+	MaxIdle     int
+	MaxActive   int
+	IdleTimeout time.Duration
+	Wait        bool
+}
+
+// InitWithConfig initializes the RedisActivityStream with the connection configured by config. It returns an error if
+// the configuration is invalid, Redis is not dialed until the first command.
+func (as *RedisActivityStream) InitWithConfig(config Config) error {
+	pool, err := config.newPool()
+	if err != nil {
+		return err
+	}
+	if as.maxStreamSize == 0 {
+		as.maxStreamSize = activitystream.DefaultMaxStreamSize
+	}
+	as.pool = pool
+	return nil
+}
+
+// withDefaults returns the configuration with all zero values replaced by the defaults
+func (config Config) withDefaults() Config {
+	if config.Protocol == "" {
+		config.Protocol = RedisDefaultProtocol
+	}
+	if config.Addr == "" {
+		config.Addr = RedisDefaultURL
+	}
+	if config.MaxIdle == 0 {
+		config.MaxIdle = RedisDefaultMaxIdle
+	}
+	if config.IdleTimeout == 0 {
+		config.IdleTimeout = RedisDefaultIdleTimeout
+	}
+	return config
+}
+
+// validate returns an error describing the first invalid setting
+func (config Config) validate() error {
+	switch {
+	case len(config.SentinelAddrs) > 0 && config.MasterName == "":
+		return errors.New("invalid Config, the MasterName monitored by the Sentinels is missing")
+	case len(config.SentinelAddrs) == 0 && config.MasterName != "":
+		return errors.New("invalid Config, the SentinelAddrs monitoring " + config.MasterName + " are missing")
+	case config.Username != "" && config.Password == "":
+		return errors.New("invalid Config, the Password of " + config.Username + " is missing")
+	case config.DB < 0:
+		return errors.New("invalid Config, DB is negative")
+	case config.DialTimeout < 0 || config.ReadTimeout < 0 || config.WriteTimeout < 0:
+		return errors.New("invalid Config, a timeout is negative")
+	case config.MaxIdle < 0 || config.MaxActive < 0 || config.IdleTimeout < 0:
+		return errors.New("invalid Config, a setting of the pool is negative")
+	}
+	return nil
+}
+
+// newPool returns a connection pool dialing Redis as configured
+func (config Config) newPool() (*redis.Pool, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	config = config.withDefaults()
+
+	dial := func() (redis.Conn, error) {
+		return config.dial(config.Addr)
+	}
+	test := func(c redis.Conn, t time.Time) error {
+		_, err := c.Do("PING")
+		return err
+	}
+	if len(config.SentinelAddrs) > 0 {
+		s := &sentinel{config: config, addrs: config.SentinelAddrs}
+		dial = func() (redis.Conn, error) {
+			addr, err := s.master()
+			if err != nil {
+				return nil, err
+			}
+			return config.dial(addr)
+		}
+		test = func(c redis.Conn, t time.Time) error {
+			return testRole(c, "master")
+		}
+	}
+	return config.pool(dial, test), nil
+}
+
+// pool returns a connection pool with the configured size using dial and testing connections taken from the pool
+func (config Config) pool(dial func() (redis.Conn, error), test func(c redis.Conn, t time.Time) error) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:      config.MaxIdle,
+		MaxActive:    config.MaxActive,
+		IdleTimeout:  config.IdleTimeout,
+		Wait:         config.Wait,
+		Dial:         dial,
+		TestOnBorrow: test,
+	}
+}
+
+// dialOptions returns the options of redigo to dial Redis or a Sentinel
+func (config Config) dialOptions() []redis.DialOption {
+	return []redis.DialOption{
+		redis.DialConnectTimeout(config.DialTimeout),
+		redis.DialReadTimeout(config.ReadTimeout),
+		redis.DialWriteTimeout(config.WriteTimeout),
+		redis.DialUseTLS(config.TLS),
+		redis.DialTLSConfig(config.TLSConfig),
+	}
+}
+
+// dial connects to the Redis at addr, authenticates and selects the database
+func (config Config) dial(addr string) (redis.Conn, error) {
+	c, err := redis.Dial(config.Protocol, addr, config.dialOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	// AUTH is sent by hand, as redigo does not support ACL users, and must precede SELECT
+	if config.Password != "" {
+		args := []interface{}{config.Password}
+		if config.Username != "" {
+			args = []interface{}{config.Username, config.Password}
+		}
+		if _, err := c.Do("AUTH", args...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if config.DB != 0 {
+		if _, err := c.Do("SELECT", config.DB); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// testRole returns an error if the Redis connected to does not have the role, e.g. "master"
+func testRole(c redis.Conn, role string) error {
+	reply, err := redis.Values(c.Do("ROLE"))
+	if err != nil {
+		return err
+	}
+	if len(reply) == 0 {
+		return errors.New("invalid reply of ROLE")
+	}
+	if actual, _ := redis.String(reply[0], nil); actual != role {
+		return errors.New("connected to a " + actual + " instead of a " + role)
+	}
+	return nil
+}
+
+// sentinel discovers the address of a master through the Sentinels monitoring it
+type sentinel struct {
+	config Config
+
+	mutex sync.Mutex
+	// addrs are the addresses of the Sentinels, the last one which replied first
+	addrs []string
+}
+
+// master returns the address of the master as replied by the first Sentinel which knows it
+func (s *sentinel) master() (string, error) {
+	s.mutex.Lock()
+	addrs := append([]string{}, s.addrs...)
+	s.mutex.Unlock()
+
+	err := errors.New("no Sentinel given")
+	for i, addr := range addrs {
+		var master string
+		if master, err = s.ask(addr); err == nil {
+			if i > 0 {
+				s.mutex.Lock()
+				s.addrs = append(append([]string{addr}, addrs[:i]...), addrs[i+1:]...)
+				s.mutex.Unlock()
+			}
+			return master, nil
+		}
+	}
+	return "", mapError(err)
+}
+
+// ask returns the address of the master as replied by the Sentinel at addr
+func (s *sentinel) ask(addr string) (string, error) {
+	c, err := redis.Dial(s.config.Protocol, addr, s.config.dialOptions()...)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	if s.config.SentinelPassword != "" {
+		if _, err := c.Do("AUTH", s.config.SentinelPassword); err != nil {
+			return "", err
+		}
+	}
+
+	hostAndPort, err := redis.Strings(c.Do("SENTINEL", "get-master-addr-by-name", s.config.MasterName))
+	if err == redis.ErrNil {
+		return "", errors.New("master " + s.config.MasterName + " is unknown to the Sentinel at " + addr)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(hostAndPort) != 2 {
+		return "", errors.New("invalid reply of SENTINEL get-master-addr-by-name")
+	}
+	return net.JoinHostPort(hostAndPort[0], hostAndPort[1]), nil
+}
@@ -0,0 +1,230 @@
+package redisstream
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	testing "testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	Convey("Subject: Test validating the Config", t, func() {
+		Convey("When Sentinels are configured without master", func() {
+			err := (&RedisActivityStream{}).InitWithConfig(Config{SentinelAddrs: []string{":26379"}})
+
+			Convey("It should return an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+		Convey("When invalid values are configured", func() {
+			configs := []Config{
+				{MasterName: "mymaster"},
+				{Username: "user"},
+				{DB: -1},
+				{ReadTimeout: -time.Second},
+				{MaxActive: -1},
+			}
+
+			Convey("It should return an error", func() {
+				for _, config := range configs {
+					So(config.validate(), ShouldNotBeNil)
+				}
+			})
+		})
+		Convey("When the pool is not configured", func() {
+			pool, err := Config{}.newPool()
+			So(err, ShouldBeNil)
+
+			Convey("It should use the defaults", func() {
+				So(pool.MaxIdle, ShouldEqual, RedisDefaultMaxIdle)
+				So(pool.IdleTimeout, ShouldEqual, RedisDefaultIdleTimeout)
+				So(pool.MaxActive, ShouldEqual, 0)
+			})
+		})
+		Convey("When the pool is configured", func() {
+			pool, err := Config{MaxIdle: 10, MaxActive: 20, IdleTimeout: time.Minute, Wait: true}.newPool()
+			So(err, ShouldBeNil)
+
+			Convey("It should be sized accordingly", func() {
+				So(pool.MaxIdle, ShouldEqual, 10)
+				So(pool.MaxActive, ShouldEqual, 20)
+				So(pool.IdleTimeout, ShouldEqual, time.Minute)
+				So(pool.Wait, ShouldBeTrue)
+			})
+		})
+	})
+}
+
+// fakeSentinel replies to SENTINEL get-master-addr-by-name with the address of master and records all commands
+type fakeSentinel struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	master   string
+	commands []string
+}
+
+func startFakeSentinel(master string) *fakeSentinel {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	s := &fakeSentinel{listener: listener, master: master}
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSentinel) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		// commands are sent by redigo as arrays of bulk strings
+		var n int
+		if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var length int
+			if _, err := fmt.Fscanf(r, "$%d\r\n", &length); err != nil {
+				return
+			}
+			arg := make([]byte, length+2)
+			if _, err := io.ReadFull(r, arg); err != nil {
+				return
+			}
+			args[i] = string(arg[:length])
+		}
+
+		s.mutex.Lock()
+		s.commands = append(s.commands, strings.Join(args, " "))
+		master := s.master
+		s.mutex.Unlock()
+		switch {
+		case args[0] == "AUTH":
+			fmt.Fprint(c, "+OK\r\n")
+		case len(args) == 3 && args[1] == "get-master-addr-by-name" && args[2] == "mymaster":
+			host, port, _ := net.SplitHostPort(master)
+			fmt.Fprintf(c, "*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(host), host, len(port), port)
+		case args[0] == "SENTINEL":
+			fmt.Fprint(c, "*-1\r\n")
+		default:
+			fmt.Fprint(c, "-ERR unknown command\r\n")
+		}
+	}
+}
+
+func TestInitWithConfig(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	_, port, _ := net.SplitHostPort(address)
+	master := "127.0.0.1:" + port
+	testActivity := createTestActivity()
+	cleanUp := func() {
+		removeFromRedis(testActivity.Id)
+		c, err := redis.Dial(protocol, address, redis.DialDatabase(1))
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+		c.Do("DEL", testActivity.Id)
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test initializing a RedisActivityStream with a Config", t, func() {
+		cleanUp()
+
+		Convey("When the master is discovered through Sentinel", func() {
+			s := startFakeSentinel(master)
+			defer s.listener.Close()
+			// the first Sentinel is not reachable
+			unreachable, _ := net.Listen("tcp", "127.0.0.1:0")
+			unreachable.Close()
+			asUnderTest := RedisActivityStream{}
+			err := asUnderTest.InitWithConfig(Config{
+				SentinelAddrs:    []string{unreachable.Addr().String(), s.listener.Addr().String()},
+				MasterName:       "mymaster",
+				SentinelPassword: "SENTINEL_PASSWORD",
+				DialTimeout:      time.Second,
+			})
+			So(err, ShouldBeNil)
+
+			Convey("It should connect to the master", func() {
+				So(asUnderTest.Store(testActivity), ShouldBeNil)
+				activity, err := asUnderTest.Get(testActivity.Id)
+				So(err, ShouldBeNil)
+				So(activity.Id, ShouldEqual, testActivity.Id)
+				s.mutex.Lock()
+				defer s.mutex.Unlock()
+				So(s.commands[0], ShouldEqual, "AUTH SENTINEL_PASSWORD")
+				So(s.commands[1], ShouldEqual, "SENTINEL get-master-addr-by-name mymaster")
+			})
+			Convey("It should dial the new master after failover", func() {
+				So(asUnderTest.Store(testActivity), ShouldBeNil)
+				// keeps the idle connection to the former master busy
+				c := asUnderTest.pool.Get()
+				defer c.Close()
+				s.mutex.Lock()
+				s.master = unreachable.Addr().String()
+				s.mutex.Unlock()
+				_, err := asUnderTest.Get(testActivity.Id)
+				So(errors.Is(err, activitystream.ErrBackendUnavailable), ShouldBeTrue)
+			})
+		})
+		Convey("When the Sentinels do not know the master", func() {
+			s := startFakeSentinel(master)
+			defer s.listener.Close()
+			asUnderTest := RedisActivityStream{}
+			err := asUnderTest.InitWithConfig(Config{SentinelAddrs: []string{s.listener.Addr().String()}, MasterName: "unknown"})
+			So(err, ShouldBeNil)
+
+			Convey("It should return an error", func() {
+				_, err := asUnderTest.Get(testActivity.Id)
+				So(err, ShouldNotBeNil)
+			})
+		})
+		Convey("When a database is selected", func() {
+			asUnderTest := RedisActivityStream{}
+			err := asUnderTest.InitWithConfig(Config{Protocol: protocol, Addr: address, DB: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+			So(err, ShouldBeNil)
+			So(asUnderTest.Store(testActivity), ShouldBeNil)
+
+			Convey("It should store the activities in the database", func() {
+				_, err := asUnderTest.Get(testActivity.Id)
+				So(err, ShouldBeNil)
+				defaultDB, err := NewRedisActivityStream(WithAddr(protocol, address))
+				So(err, ShouldBeNil)
+				_, err = defaultDB.Get(testActivity.Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+			})
+		})
+		Convey("When the credentials are wrong", func() {
+			asUnderTest := RedisActivityStream{}
+			err := asUnderTest.InitWithConfig(Config{Addr: address, Username: "CONFIG_TEST_USER", Password: strconv.Itoa(int(time.Now().Unix()))})
+			So(err, ShouldBeNil)
+
+			Convey("It should return the error of AUTH", func() {
+				_, err := asUnderTest.Get(testActivity.Id)
+				So(err, ShouldNotBeNil)
+				So(errors.Is(err, activitystream.ErrNotFound), ShouldBeFalse)
+			})
+		})
+	})
+}
@@ -0,0 +1,55 @@
+package redisstream
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	"time"
+)
+
+// SetTombstoneTTL sets the time a tombstone replaces a deleted activity, see Delete.
+// Zero restores activitystream.DefaultTombstoneTTL, a negative duration keeps tombstones forever.
+func (as *RedisActivityStream) SetTombstoneTTL(ttl time.Duration) {
+	as.tombstoneTTL = ttl
+}
+
+// Delete replaces the activity by its tombstone, which is returned by GetStream in place of the activity until it
+// expires, see SetTombstoneTTL. Afterwards the activity is left out of its streams. Its history is removed right away.
+// Deleting a tombstone has no effect, deleting an inexistent activity returns ErrNotFound.
+// The tombstone is only written if the activity has not been changed since it was read, otherwise it is read again.
+func (as *RedisActivityStream) Delete(id string) error {
+	if err := as.checkKeys(as.activityKey(id)); err != nil {
+		return err
+	}
+	ttl := as.tombstoneTTL
+	if ttl == 0 {
+		ttl = activitystream.DefaultTombstoneTTL
+	}
+	px := int64(0)
+	if ttl > 0 {
+		px = int64(ttl / time.Millisecond)
+	}
+
+	for {
+		resp, err := as.execute("GET", as.activityKey(id))
+		activity, err := parseActivityFromResponse(resp, err)
+		if err != nil || activity.IsTombstone() {
+			return err
+		}
+
+		tombstone, err := json.Marshal(activity.Tombstone(time.Now().UTC()))
+		if err != nil {
+			return activitystream.Wrap(activitystream.ErrInvalidActivity, errors.New("marshalling Activity failed, "+err.Error()))
+		}
+		status, err := redis.Int(as.eval(scriptDeleteActivity, 3, as.activityKey(id), as.historyKey(id), as.referencesKey(id), resp, tombstone, px))
+		switch {
+		case err != nil:
+			return err
+		case status == 0:
+			return activitystream.ErrNotFound
+		case status > 0:
+			return nil
+		}
+	}
+}
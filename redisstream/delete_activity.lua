@@ -0,0 +1,17 @@
+-- Replaces the activity KEYS[1] by its tombstone ARGV[2] if it is still stored as ARGV[1] and deletes its history
+-- KEYS[2]. The tombstone and the references KEYS[3] expire after ARGV[3] milliseconds unless it is 0, so that the
+-- references are counted from 0 again once the ID is stored again. It returns 1 on success, 0 if the activity does not
+-- exist and -1 if it has been changed.
+local current=redis.call("GET",KEYS[1])
+if not current then return 0 end
+if current~=ARGV[1] then return -1 end
+local ttl=tonumber(ARGV[3])
+if ttl>0 then
+	redis.call("SET",KEYS[1],ARGV[2],"PX",ttl)
+	redis.call("PEXPIRE",KEYS[3],ttl)
+else
+	redis.call("SET",KEYS[1],ARGV[2])
+	redis.call("PERSIST",KEYS[3])
+end
+redis.call("DEL",KEYS[2])
+return 1
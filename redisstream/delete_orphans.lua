@@ -0,0 +1,12 @@
+-- Deletes the activities KEYS[1..n] along with their references and history, named by the suffixes ARGV[1] and
+-- ARGV[2], unless their reference count has changed from ARGV[3..n+2] ("" if it did not exist), which means they have
+-- been added to a stream in the meantime. It returns the deleted IDs.
+local deleted={}
+for i,id in ipairs(KEYS) do
+	local refs=redis.call("GET",id..ARGV[1]) or ""
+	if refs==ARGV[i+2] then
+		redis.call("DEL",id,id..ARGV[1],id..ARGV[2])
+		table.insert(deleted,id)
+	end
+end
+return deleted
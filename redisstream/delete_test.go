@@ -0,0 +1,123 @@
+package redisstream
+
+import (
+	"errors"
+	"github.com/chrisport/go-activitystream/activitystream"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+	"time"
+)
+
+func TestDelete(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	asUnderTest := RedisActivityStream{}
+	asUnderTest.Init(protocol, address)
+
+	testStreamID := "DELETE_STREAM_ID"
+	start := time.Now().UTC()
+	testActivities := []activitystream.Activity{createTestActivity(), createTestActivity()}
+	for i := range testActivities {
+		testActivities[i].Published = start.Add(time.Duration(i) * time.Millisecond)
+		testActivities[i].Object.Content = "some content"
+		testActivities[i].To = []string{activitystream.PublicAudience}
+	}
+	deleted := testActivities[0]
+	cleanUp := func() {
+		removeFromRedis(testStreamID, deleted.Id, asUnderTest.historyKey(deleted.Id), asUnderTest.referencesKey(deleted.Id),
+			testActivities[1].Id, asUnderTest.referencesKey(testActivities[1].Id))
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test Delete of activities", t, func() {
+		cleanUp()
+		for i := range testActivities {
+			So(asUnderTest.AddToStreams(testActivities[i], testStreamID), ShouldBeEmpty)
+		}
+		So(asUnderTest.Update(deleted, ""), ShouldBeNil)
+
+		Convey("When an activity is deleted", func() {
+			So(asUnderTest.Delete(deleted.Id), ShouldBeNil)
+
+			Convey("It should be returned as tombstone in place of the activity", func() {
+				stream, err := asUnderTest.GetStreamForViewer(testStreamID, "", 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 2)
+				So(stream[1].Id, ShouldEqual, deleted.Id)
+				So(stream[1].IsTombstone(), ShouldBeTrue)
+				So(stream[1].Object.Content, ShouldBeEmpty)
+				So(stream[1].Actor.Id, ShouldEqual, deleted.Actor.Id)
+				So(stream[1].Published.Equal(deleted.Published), ShouldBeTrue)
+			})
+			Convey("It should remove its history", func() {
+				history, err := asUnderTest.History(deleted.Id)
+				So(err, ShouldBeNil)
+				So(history, ShouldBeEmpty)
+			})
+			Convey("It should expire the tombstone", func() {
+				ttl, err := asUnderTest.execute("PTTL", deleted.Id)
+				So(err, ShouldBeNil)
+				So(ttl, ShouldBeGreaterThan, int64(activitystream.DefaultTombstoneTTL/time.Millisecond)-int64(time.Minute/time.Millisecond))
+			})
+			Convey("It should expire its references along with the tombstone", func() {
+				ttl, err := asUnderTest.execute("PTTL", asUnderTest.referencesKey(deleted.Id))
+				So(err, ShouldBeNil)
+				So(ttl, ShouldBeGreaterThan, int64(activitystream.DefaultTombstoneTTL/time.Millisecond)-int64(time.Minute/time.Millisecond))
+			})
+			Convey("It should not be updated anymore", func() {
+				So(asUnderTest.Update(deleted, "1"), ShouldEqual, activitystream.ErrNotFound)
+			})
+			Convey("It should not be stored again", func() {
+				err := asUnderTest.Store(deleted)
+				So(errors.Is(err, activitystream.ErrInvalidActivity), ShouldBeTrue)
+				activity, err := asUnderTest.Get(deleted.Id)
+				So(err, ShouldBeNil)
+				So(activity.IsTombstone(), ShouldBeTrue)
+			})
+			Convey("It should have no effect to delete it again", func() {
+				So(asUnderTest.Delete(deleted.Id), ShouldBeNil)
+			})
+		})
+		Convey("When the tombstone has expired", func() {
+			asUnderTest.SetTombstoneTTL(time.Millisecond)
+			defer asUnderTest.SetTombstoneTTL(0)
+			So(asUnderTest.Delete(deleted.Id), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+
+			Convey("It should be left out of the stream", func() {
+				stream, err := asUnderTest.GetStream(testStreamID, 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 1)
+				So(stream[0].Id, ShouldEqual, testActivities[1].Id)
+			})
+			Convey("It should remove its references along with the tombstone", func() {
+				exists, err := asUnderTest.execute("EXISTS", asUnderTest.referencesKey(deleted.Id))
+				So(err, ShouldBeNil)
+				So(exists, ShouldEqual, 0)
+			})
+		})
+		Convey("When the activity is changed after it has been read for deletion", func() {
+			read, err := asUnderTest.execute("GET", deleted.Id)
+			So(err, ShouldBeNil)
+			So(asUnderTest.Update(deleted, "1"), ShouldBeNil)
+			status, err := asUnderTest.eval(scriptDeleteActivity, 3, deleted.Id, asUnderTest.historyKey(deleted.Id), asUnderTest.referencesKey(deleted.Id), read, "{}", 0)
+
+			Convey("It should not write the tombstone", func() {
+				So(err, ShouldBeNil)
+				So(status, ShouldEqual, -1)
+				activity, err := asUnderTest.Get(deleted.Id)
+				So(err, ShouldBeNil)
+				So(activity.Version, ShouldEqual, "2")
+				history, err := asUnderTest.History(deleted.Id)
+				So(err, ShouldBeNil)
+				So(len(history), ShouldEqual, 2)
+			})
+		})
+		Convey("When an inexistent activity is deleted", func() {
+			Convey("It should return ErrNotFound", func() {
+				So(asUnderTest.Delete("SOME_INEXISTENT_KEY"), ShouldEqual, activitystream.ErrNotFound)
+			})
+		})
+	})
+}
@@ -0,0 +1,64 @@
+package redisstream
+
+import (
+	"errors"
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	"io"
+	"net"
+)
+
+// conn wraps a connection of the pool and maps its errors to the errors of activitystream
+type conn struct {
+	redis.Conn
+}
+
+// conn returns a connection of the pool, or one routing commands to the nodes of the cluster. It must be closed after
+// use. It counts as write of the caller, see SetReadYourWrites.
+func (as *RedisActivityStream) conn() redis.Conn {
+	if as.replication != nil {
+		as.replication.wrote(as.tenant + as.callerId)
+	}
+	return as.primaryConn()
+}
+
+// primaryConn returns a connection like conn without counting as write
+func (as *RedisActivityStream) primaryConn() redis.Conn {
+	if as.cluster != nil {
+		return conn{as.cluster.conn()}
+	}
+	return conn{as.pool.Get()}
+}
+
+func (c conn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Conn.Do(cmd, args...)
+	return reply, mapError(err)
+}
+
+func (c conn) Send(cmd string, args ...interface{}) error {
+	return mapError(c.Conn.Send(cmd, args...))
+}
+
+func (c conn) Flush() error {
+	return mapError(c.Conn.Flush())
+}
+
+func (c conn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	return reply, mapError(err)
+}
+
+// mapError wraps errors of redigo with the corresponding error of activitystream. Errors replied by Redis are returned
+// as they are.
+func mapError(err error) error {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return nil
+	case err == redis.ErrNil:
+		return activitystream.Wrap(activitystream.ErrNotFound, err)
+	case err == redis.ErrPoolExhausted, err == io.EOF, err == io.ErrUnexpectedEOF, errors.As(err, &netErr):
+		return activitystream.Wrap(activitystream.ErrBackendUnavailable, err)
+	}
+	return err
+}
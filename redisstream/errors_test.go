@@ -0,0 +1,68 @@
+package redisstream
+
+import (
+	"errors"
+	"github.com/chrisport/go-activitystream/activitystream"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+	"time"
+)
+
+func TestErrorMapping(t *testing.T) {
+	Convey("Subject: Test mapping of Redis errors to activitystream errors", t, func() {
+		Convey("When Redis is not reachable", func() {
+			asUnderTest := RedisActivityStream{}
+			asUnderTest.Init(protocol, "127.0.0.1:1")
+
+			Convey("It should return ErrBackendUnavailable", func() {
+				_, err := asUnderTest.Get("SOME_ID")
+				So(errors.Is(err, activitystream.ErrBackendUnavailable), ShouldBeTrue)
+				_, err = asUnderTest.BulkGet("SOME_ID")
+				So(errors.Is(err, activitystream.ErrBackendUnavailable), ShouldBeTrue)
+				errs := asUnderTest.AddToStreams(createTestActivity(), "SOME_STREAM_ID")
+				So(len(errs), ShouldBeGreaterThan, 0)
+				So(errors.Is(errs[0], activitystream.ErrBackendUnavailable), ShouldBeTrue)
+			})
+		})
+	})
+
+	if skipIntegrationTests {
+		return
+	}
+	asUnderTest := RedisActivityStream{}
+	asUnderTest.Init(protocol, address)
+	testStreamID := "ERROR_STREAM_ID"
+
+	Convey("Subject: Test errors of a reachable Redis", t, func() {
+		Convey("When a key does not contain an activity", func() {
+			defer removeFromRedis("SOME_KEY")
+			_, err := asUnderTest.execute("SET", "SOME_KEY", "NOT_AN_ACTIVITY")
+			So(err, ShouldBeNil)
+
+			Convey("It should return ErrInvalidActivity", func() {
+				_, err := asUnderTest.Get("SOME_KEY")
+				So(errors.Is(err, activitystream.ErrInvalidActivity), ShouldBeTrue)
+			})
+		})
+		Convey("When an activity is added to a full stream with newer activities only", func() {
+			asUnderTest.SetMaxStreamSize(1)
+			defer asUnderTest.SetMaxStreamSize(activitystream.DefaultMaxStreamSize)
+			newActivity := createTestActivity()
+			oldActivity := createTestActivity()
+			oldActivity.Published = newActivity.Published.Add(-time.Hour)
+			defer removeFromRedis(testStreamID, newActivity.Id, oldActivity.Id)
+
+			So(asUnderTest.AddToStreams(newActivity, testStreamID), ShouldBeEmpty)
+			errs := asUnderTest.AddToStreams(oldActivity, testStreamID)
+
+			Convey("It should return ErrStreamFull", func() {
+				So(len(errs), ShouldEqual, 1)
+				So(errors.Is(errs[0], activitystream.ErrStreamFull), ShouldBeTrue)
+				stream, err := asUnderTest.GetStream(testStreamID, 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 1)
+				So(stream[0].Id, ShouldEqual, newActivity.Id)
+			})
+		})
+	})
+}
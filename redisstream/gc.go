@@ -0,0 +1,199 @@
+package redisstream
+
+import (
+	redis "github.com/garyburd/redigo/redis"
+	"strings"
+	"time"
+)
+
+// DefaultGCBatchSize is the number of keys scanned at once by CollectGarbage by default
+const DefaultGCBatchSize = 100
+
+// GCOptions configures CollectGarbage
+type GCOptions struct {
+	// DryRun only reports orphaned activities without deleting them
+	DryRun bool
+	// MinAge leaves out activities published within MinAge, which may be stored but not yet added to a stream
+	MinAge time.Duration
+	// BatchSize is the number of keys scanned at once, DefaultGCBatchSize if not set
+	BatchSize int
+}
+
+// GCReport is the result of CollectGarbage
+type GCReport struct {
+	// Scanned is the number of activities found in the database
+	Scanned int
+	// Orphaned are the IDs of activities which are referenced by no stream
+	Orphaned []string
+	// Deleted is the number of orphaned activities which have been deleted, always 0 on a dry run
+	Deleted int
+}
+
+// CollectGarbage finds activities which are referenced by no stream and deletes them along with their history.
+// Other than the reference counting of RetentionPolicy.CollectGarbage it also finds activities which were stored
+// without counting, e.g. through Store. A stream is any sorted set in the Namespace of streams, including pinned
+// activities. The whole database is scanned, so this is meant to run as a background job, see StartGarbageCollector.
+// On a tenant only its own keys are scanned, see ForTenant. Keys which contain no activity are never touched. Activities which are added to a stream while the collector runs
+// are kept if their references are counted, otherwise only MinAge protects them.
+func (as *RedisActivityStream) CollectGarbage(options GCOptions) (GCReport, error) {
+	var report GCReport
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultGCBatchSize
+	}
+
+	streams, candidates, err := as.scanKeys(options.BatchSize)
+	if err != nil {
+		return report, err
+	}
+	referenced, err := as.referencedIds(streams, options.BatchSize)
+	if err != nil {
+		return report, err
+	}
+
+	c := as.conn()
+	defer c.Close()
+	minPublished := time.Now().Add(-options.MinAge)
+	for start := 0; start < len(candidates); start += options.BatchSize {
+		end := start + options.BatchSize
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+		// read every activity along with its reference count
+		keys := make([]string, 0, 2*(end-start))
+		for _, id := range candidates[start:end] {
+			keys = append(keys, as.activityKey(id), as.referencesKey(id))
+		}
+		values, err := as.mget(c, keys)
+		if err != nil {
+			return report, err
+		}
+
+		orphaned := make([]string, 0)
+		refs := make([]interface{}, 0)
+		for i, id := range candidates[start:end] {
+			activity, err := parseActivityFromResponse(values[2*i], nil)
+			if err != nil || activity.Id != id {
+				// no activity stored under its own ID
+				continue
+			}
+			report.Scanned++
+			if referenced[id] || activity.Published.After(minPublished) {
+				continue
+			}
+			report.Orphaned = append(report.Orphaned, id)
+			orphaned = append(orphaned, as.activityKey(id))
+			count, _ := redis.String(values[2*i+1], nil)
+			refs = append(refs, count)
+		}
+		if options.DryRun || len(orphaned) == 0 {
+			continue
+		}
+
+		// on a cluster the activities are deleted per slot
+		groups := as.slotGroups(orphaned)
+		calls := make([]scriptCall, len(groups))
+		for g, group := range groups {
+			args := []interface{}{len(group)}
+			for _, i := range group {
+				args = append(args, orphaned[i])
+			}
+			args = append(args, referencesSuffix, historySuffix)
+			for _, i := range group {
+				args = append(args, refs[i])
+			}
+			calls[g] = scriptCall{scriptDeleteOrphans, args}
+		}
+		replies, err := evalPipelined(c, calls)
+		if err != nil {
+			return report, err
+		}
+		for _, reply := range replies {
+			deleted, err := redis.Strings(reply, nil)
+			if err != nil {
+				return report, err
+			}
+			report.Deleted += len(deleted)
+		}
+	}
+	return report, nil
+}
+
+// StartGarbageCollector runs CollectGarbage every interval until the returned function is called.
+// The result of every run is passed to report, which may be nil.
+func (as *RedisActivityStream) StartGarbageCollector(interval time.Duration, options GCOptions, report func(GCReport, error)) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				r, err := as.CollectGarbage(options)
+				if report != nil {
+					report(r, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// scanKeys returns all sorted sets which may be streams and the IDs of all strings which may be activities
+func (as *RedisActivityStream) scanKeys(batchSize int) (zsets, strs []string, err error) {
+	c := as.conn()
+	defer c.Close()
+
+	err = as.scan(batchSize, func(keys []string) error {
+		for _, key := range keys {
+			c.Send("TYPE", key)
+		}
+		c.Flush()
+		for _, key := range keys {
+			t, err := redis.String(c.Receive())
+			if err != nil {
+				return err
+			}
+			name, own := as.ownKey(key)
+			switch {
+			case !own:
+			case t == "zset" && strings.HasPrefix(name, as.keys.Stream):
+				zsets = append(zsets, key)
+			case t == "string" && strings.HasPrefix(name, as.keys.Activity) && !strings.HasSuffix(name, referencesSuffix):
+				strs = append(strs, as.untag(strings.TrimPrefix(name, as.keys.Activity)))
+			}
+		}
+		return nil
+	})
+	return zsets, strs, err
+}
+
+// referencedIds returns the members of all streams
+func (as *RedisActivityStream) referencedIds(streams []string, batchSize int) (map[string]bool, error) {
+	c := as.conn()
+	defer c.Close()
+
+	referenced := make(map[string]bool)
+	for _, stream := range streams {
+		cursor := "0"
+		for {
+			reply, err := redis.Values(c.Do("ZSCAN", stream, cursor, "COUNT", batchSize))
+			if err != nil {
+				return nil, err
+			}
+			var members []string
+			if _, err := redis.Scan(reply, &cursor, &members); err != nil {
+				return nil, err
+			}
+			// members alternate with their scores
+			for i := 0; i < len(members); i += 2 {
+				referenced[members[i]] = true
+			}
+			if cursor == "0" {
+				break
+			}
+		}
+	}
+	return referenced, nil
+}
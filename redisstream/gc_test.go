@@ -0,0 +1,93 @@
+package redisstream
+
+import (
+	"github.com/chrisport/go-activitystream/activitystream"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+	"time"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	asUnderTest := RedisActivityStream{}
+	asUnderTest.Init(protocol, address)
+
+	testStreamID := "GC_STREAM_ID"
+	referenced := createTestActivity()
+	referenced.Published = time.Now().UTC().Add(-time.Hour)
+	orphaned := createTestActivity()
+	orphaned.Published = time.Now().UTC().Add(-time.Hour)
+	recent := createTestActivity()
+	cleanUp := func() {
+		removeFromRedis(testStreamID, "GC_NOT_AN_ACTIVITY")
+		for _, id := range []string{referenced.Id, orphaned.Id, recent.Id} {
+			removeFromRedis(id, asUnderTest.referencesKey(id), asUnderTest.historyKey(id))
+		}
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test garbage collection of orphaned activities", t, func() {
+		cleanUp()
+		So(asUnderTest.AddToStreams(referenced, testStreamID), ShouldBeEmpty)
+		So(asUnderTest.Store(orphaned), ShouldBeNil)
+		So(asUnderTest.Update(orphaned, ""), ShouldBeNil)
+		So(asUnderTest.Store(recent), ShouldBeNil)
+		_, err := asUnderTest.execute("SET", "GC_NOT_AN_ACTIVITY", "{}")
+		So(err, ShouldBeNil)
+
+		Convey("When a dry run is done", func() {
+			report, err := asUnderTest.CollectGarbage(GCOptions{DryRun: true, MinAge: time.Minute, BatchSize: 2})
+			So(err, ShouldBeNil)
+
+			Convey("It should report orphaned activities without deleting them", func() {
+				So(report.Orphaned, ShouldContain, orphaned.Id)
+				So(report.Orphaned, ShouldNotContain, referenced.Id)
+				So(report.Orphaned, ShouldNotContain, recent.Id)
+				So(report.Scanned, ShouldBeGreaterThanOrEqualTo, 3)
+				So(report.Deleted, ShouldEqual, 0)
+				_, err := asUnderTest.Get(orphaned.Id)
+				So(err, ShouldBeNil)
+			})
+		})
+		Convey("When garbage is collected", func() {
+			report, err := asUnderTest.CollectGarbage(GCOptions{MinAge: time.Minute, BatchSize: 2})
+			So(err, ShouldBeNil)
+
+			Convey("It should delete orphaned activities along with their history", func() {
+				So(report.Orphaned, ShouldContain, orphaned.Id)
+				So(report.Deleted, ShouldEqual, len(report.Orphaned))
+				_, err := asUnderTest.Get(orphaned.Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+				history, err := asUnderTest.History(orphaned.Id)
+				So(err, ShouldBeNil)
+				So(history, ShouldBeEmpty)
+			})
+			Convey("It should keep referenced and recent activities and other keys", func() {
+				_, err := asUnderTest.Get(referenced.Id)
+				So(err, ShouldBeNil)
+				_, err = asUnderTest.Get(recent.Id)
+				So(err, ShouldBeNil)
+				value, err := asUnderTest.execute("GET", "GC_NOT_AN_ACTIVITY")
+				So(err, ShouldBeNil)
+				So(value, ShouldNotBeNil)
+			})
+		})
+		Convey("When the garbage collector is started", func() {
+			reports := make(chan GCReport, 1)
+			stop := asUnderTest.StartGarbageCollector(time.Millisecond, GCOptions{MinAge: time.Minute}, func(r GCReport, err error) {
+				select {
+				case reports <- r:
+				default:
+				}
+			})
+			report := <-reports
+			stop()
+
+			Convey("It should collect garbage periodically", func() {
+				So(report.Orphaned, ShouldContain, orphaned.Id)
+			})
+		})
+	})
+}
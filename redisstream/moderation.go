@@ -0,0 +1,69 @@
+package redisstream
+
+const (
+	mutedSuffix     = "-muted"
+	blockedSuffix   = "-blocked"
+	blockedBySuffix = "-blockedby"
+)
+
+// mutedKey returns the key of the set of actor IDs the viewer has muted
+func (as *RedisActivityStream) mutedKey(viewerId string) string {
+	return as.actorKey(viewerId) + mutedSuffix
+}
+
+// blockedKey returns the key of the set of actor IDs the viewer has blocked
+func (as *RedisActivityStream) blockedKey(viewerId string) string {
+	return as.actorKey(viewerId) + blockedSuffix
+}
+
+// blockedByKey returns the key of the set of actor IDs which have blocked the viewer
+func (as *RedisActivityStream) blockedByKey(viewerId string) string {
+	return as.actorKey(viewerId) + blockedBySuffix
+}
+
+// Mute hides all activities of the actor from the viewer when reading through GetStreamForViewer.
+func (as *RedisActivityStream) Mute(viewerId, actorId string) error {
+	if err := as.checkKeys(as.mutedKey(viewerId)); err != nil {
+		return err
+	}
+	_, err := as.execute("SADD", as.mutedKey(viewerId), actorId)
+	return err
+}
+
+// Unmute reverts Mute, the activities of the actor will be visible to the viewer again.
+func (as *RedisActivityStream) Unmute(viewerId, actorId string) error {
+	if err := as.checkKeys(as.mutedKey(viewerId)); err != nil {
+		return err
+	}
+	_, err := as.execute("SREM", as.mutedKey(viewerId), actorId)
+	return err
+}
+
+// Block hides all activities of the actor from the viewer and all activities of the viewer from the actor when
+// reading through GetStreamForViewer.
+func (as *RedisActivityStream) Block(viewerId, actorId string) error {
+	if err := as.checkKeys(as.blockedKey(viewerId), as.blockedByKey(actorId)); err != nil {
+		return err
+	}
+	c := as.conn()
+	defer c.Close()
+
+	as.multi(c)
+	c.Send("SADD", as.blockedKey(viewerId), actorId)
+	c.Send("SADD", as.blockedByKey(actorId), viewerId)
+	return as.exec(c)
+}
+
+// Unblock reverts Block, viewer and actor will see each others activities again.
+func (as *RedisActivityStream) Unblock(viewerId, actorId string) error {
+	if err := as.checkKeys(as.blockedKey(viewerId), as.blockedByKey(actorId)); err != nil {
+		return err
+	}
+	c := as.conn()
+	defer c.Close()
+
+	as.multi(c)
+	c.Send("SREM", as.blockedKey(viewerId), actorId)
+	c.Send("SREM", as.blockedByKey(actorId), viewerId)
+	return as.exec(c)
+}
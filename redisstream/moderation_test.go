@@ -0,0 +1,132 @@
+package redisstream
+
+import (
+	"github.com/chrisport/go-activitystream/activitystream"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+	"time"
+)
+
+func TestMuteAndBlock(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	asUnderTest := RedisActivityStream{}
+	asUnderTest.Init(protocol, address)
+
+	testStreamID := "MODERATION_STREAM_ID"
+	viewerID := "VIEWER_ID"
+	start := time.Now().UTC()
+	testActivities := make([]activitystream.Activity, 6)
+	for i := range testActivities {
+		testActivities[i] = createTestActivity()
+		testActivities[i].Published = start.Add(time.Duration(i) * time.Millisecond)
+		testActivities[i].Actor.Id = "FRIENDLY_ACTOR_ID"
+		if i%2 == 1 {
+			testActivities[i].Actor.Id = "ANNOYING_ACTOR_ID"
+		}
+	}
+	cleanUp := func() {
+		removeFromRedis(testStreamID, asUnderTest.mutedKey(viewerID), asUnderTest.blockedKey(viewerID), asUnderTest.blockedByKey(viewerID))
+		removeFromRedis(asUnderTest.blockedKey("ANNOYING_ACTOR_ID"), asUnderTest.blockedByKey("ANNOYING_ACTOR_ID"))
+		for i := range testActivities {
+			removeFromRedis(testActivities[i].Id)
+		}
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test Mute and Block on GetStreamForViewer", t, func() {
+		cleanUp()
+		for i := range testActivities {
+			errs := asUnderTest.AddToStreams(testActivities[i], testStreamID)
+			So(errs, ShouldBeEmpty)
+		}
+
+		Convey("When nobody is muted or blocked", func() {
+			Convey("It should return the same stream as GetStream", func() {
+				stream, err := asUnderTest.GetStreamForViewer(testStreamID, viewerID, 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 6)
+			})
+		})
+
+		Convey("When the viewer mutes an actor", func() {
+			err := asUnderTest.Mute(viewerID, "ANNOYING_ACTOR_ID")
+			So(err, ShouldBeNil)
+
+			Convey("It should leave out the actor's activities and still fill the page", func() {
+				stream, err := asUnderTest.GetStreamForViewer(testStreamID, viewerID, 3, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 3)
+				So(activitiesAreEqual(stream[0], testActivities[4]), ShouldBeTrue)
+				So(activitiesAreEqual(stream[1], testActivities[2]), ShouldBeTrue)
+				So(activitiesAreEqual(stream[2], testActivities[0]), ShouldBeTrue)
+			})
+			Convey("It should leave out the actor's activities on pages after and before a pivot", func() {
+				stream, err := asUnderTest.GetStreamForViewer(testStreamID, viewerID, 1, testActivities[4].Score(), activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 1)
+				So(activitiesAreEqual(stream[0], testActivities[2]), ShouldBeTrue)
+
+				stream, err = asUnderTest.GetStreamForViewer(testStreamID, viewerID, 1, testActivities[0].Score(), activitystream.Before)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 1)
+				So(activitiesAreEqual(stream[0], testActivities[2]), ShouldBeTrue)
+			})
+			Convey("It should not affect GetStream", func() {
+				stream, err := asUnderTest.GetStream(testStreamID, 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 6)
+			})
+			Convey("It should show the actor's activities again after Unmute", func() {
+				err := asUnderTest.Unmute(viewerID, "ANNOYING_ACTOR_ID")
+				So(err, ShouldBeNil)
+
+				stream, err := asUnderTest.GetStreamForViewer(testStreamID, viewerID, 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 6)
+			})
+		})
+
+		Convey("When the viewer blocks an actor", func() {
+			err := asUnderTest.Block(viewerID, "ANNOYING_ACTOR_ID")
+			So(err, ShouldBeNil)
+
+			Convey("It should leave out the actor's activities for the viewer", func() {
+				stream, err := asUnderTest.GetStreamForViewer(testStreamID, viewerID, 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 3)
+			})
+			Convey("It should leave out the viewer's activities for the actor", func() {
+				for i := range testActivities {
+					if testActivities[i].Actor.Id == "FRIENDLY_ACTOR_ID" {
+						testActivities[i].Actor.Id = viewerID
+						So(asUnderTest.Store(testActivities[i]), ShouldBeNil)
+					}
+				}
+				defer func() {
+					for i := range testActivities {
+						if testActivities[i].Actor.Id == viewerID {
+							testActivities[i].Actor.Id = "FRIENDLY_ACTOR_ID"
+						}
+					}
+				}()
+
+				stream, err := asUnderTest.GetStreamForViewer(testStreamID, "ANNOYING_ACTOR_ID", 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 3)
+				for i := range stream {
+					So(stream[i].Actor.Id, ShouldEqual, "ANNOYING_ACTOR_ID")
+				}
+			})
+			Convey("It should show all activities again after Unblock", func() {
+				err := asUnderTest.Unblock(viewerID, "ANNOYING_ACTOR_ID")
+				So(err, ShouldBeNil)
+
+				stream, err := asUnderTest.GetStreamForViewer(testStreamID, viewerID, 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 6)
+			})
+		})
+	})
+}
@@ -0,0 +1,265 @@
+package redisstream
+
+import (
+	"errors"
+	redis "github.com/garyburd/redigo/redis"
+	"strings"
+)
+
+// Namespace defines the prefixes of the keys written by RedisActivityStream. Keys derived from an activity, stream or
+// actor, like the history of an activity or the pinned activities of a stream, share its prefix.
+// The zero Namespace writes all keys under their raw ID, which is the default.
+type Namespace struct {
+	// Activity is the prefix of activities, their history and reference count
+	Activity string
+	// Stream is the prefix of streams and their pinned activities
+	Stream string
+	// Actor is the prefix of the muted, blocked and blocked-by sets of actors and the members of collections
+	Actor string
+}
+
+// NewNamespace returns the Namespace which prefixes all keys with name, e.g. "as" results in keys like
+// "as:act:ID", "as:stream:ID" and "as:actor:ID-muted".
+func NewNamespace(name string) Namespace {
+	return Namespace{
+		Activity: name + ":act:",
+		Stream:   name + ":stream:",
+		Actor:    name + ":actor:",
+	}
+}
+
+// SetNamespace sets the prefixes of all keys.
+// Important: Existing keys are not renamed, use MigrateKeys to move them to the new Namespace.
+func (as *RedisActivityStream) SetNamespace(namespace Namespace) {
+	as.keys = namespace
+}
+
+// activityKey returns the key of the activity
+func (as *RedisActivityStream) activityKey(activityId string) string {
+	return as.tenant + as.keys.Activity + as.tag(activityId)
+}
+
+// streamKey returns the key of the sorted set of activity IDs of the stream
+func (as *RedisActivityStream) streamKey(streamId string) string {
+	return as.tenant + as.keys.Stream + as.tag(streamId)
+}
+
+// actorKey returns the prefix of the keys of the actor
+func (as *RedisActivityStream) actorKey(actorId string) string {
+	return as.tenant + as.keys.Actor + as.tag(actorId)
+}
+
+// MigrateKeys renames the keys of the Namespace from to those of the Namespace to, e.g. to move existing data from one
+// prefixed Namespace to another. It returns the renamed keys mapped to their new name.
+// Keys are recognized by their type and name: strings containing an activity stored under its ID and their reference
+// counts, lists of history, sorted sets as streams and sets of the moderation and collection suffixes. As in
+// CollectGarbage, every sorted set with the stream prefix is taken as stream. Keys which already belong to the
+// Namespace to are left out.
+// As keys without prefix can not be told apart from those of other applications sharing the Redis, every prefix of
+// the Namespace from must be set, use MigrateIds to migrate from the default Namespace.
+// A dry run only returns the keys which would be renamed. Existing keys are never overwritten, a key whose new name
+// is taken fails the migration, the keys renamed until then keep their new name.
+// Only the keys of the tenant are migrated, the keys of sub-tenants are left out, see ForTenant.
+// On a cluster keys keep their slot, as the Namespace is not part of their hash tag.
+func (as *RedisActivityStream) MigrateKeys(from, to Namespace, dryRun bool) (map[string]string, error) {
+	if from.Activity == "" || from.Stream == "" || from.Actor == "" {
+		return nil, errors.New("migrating from a Namespace without prefixes would take keys of other applications, use MigrateIds")
+	}
+	renames := make(map[string]string)
+	c := as.conn()
+	defer c.Close()
+
+	err := as.scan(DefaultGCBatchSize, func(keys []string) error {
+		for _, key := range keys {
+			c.Send("TYPE", key)
+		}
+		c.Flush()
+		types := make([]string, len(keys))
+		for i := range keys {
+			var err error
+			if types[i], err = redis.String(c.Receive()); err != nil {
+				return err
+			}
+		}
+
+		for i, key := range keys {
+			name, own := as.ownKey(key)
+			if !own || (belongsTo(to, name) && !belongsTo(from, name)) {
+				continue
+			}
+			newName, err := as.migratedKey(c, from, to, key, name, types[i])
+			if err != nil {
+				return err
+			}
+			if err := as.rename(c, renames, name, newName, dryRun); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return renames, err
+}
+
+// MigrationIds are the IDs whose keys are renamed by MigrateIds
+type MigrationIds struct {
+	// Streams are migrated along with their pinned activities and all activities they contain, including the
+	// moderation sets of the actors and the members of the collections the activities are addressed to
+	Streams []string
+	// Actors are the IDs of further actors and collections whose moderation sets and members are migrated, e.g. of
+	// viewers who have muted other actors
+	Actors []string
+}
+
+// MigrateIds renames the keys of the IDs from the Namespace from to the Namespace to like MigrateKeys, but only the keys
+// derived from the IDs instead of all keys of the Namespace. Unlike MigrateKeys it can migrate from the default
+// Namespace, which shares the keyspace with other applications. Actors who have blocked an actor of a migrated
+// activity are migrated as well.
+func (as *RedisActivityStream) MigrateIds(from, to Namespace, ids MigrationIds, dryRun bool) (map[string]string, error) {
+	renames := make(map[string]string)
+	c := as.conn()
+	defer c.Close()
+
+	activityIds := make([]string, 0)
+	actorIds := append([]string{}, ids.Actors...)
+	seen := make(map[string]bool)
+	for _, streamId := range ids.Streams {
+		for _, name := range []string{from.Stream + as.tag(streamId), from.Stream + as.tag(streamId) + "-pinned"} {
+			members, err := redis.Strings(c.Do("ZRANGE", as.tenant+name, 0, -1))
+			if err != nil {
+				return renames, err
+			}
+			if len(members) == 0 {
+				continue
+			}
+			for _, id := range members {
+				if !seen["activity "+id] {
+					seen["activity "+id] = true
+					activityIds = append(activityIds, id)
+				}
+			}
+			if err := as.rename(c, renames, name, to.Stream+strings.TrimPrefix(name, from.Stream), dryRun); err != nil {
+				return renames, err
+			}
+		}
+	}
+
+	for _, id := range activityIds {
+		name := from.Activity + as.tag(id)
+		activity, err := parseActivityFromResponse(c.Do("GET", as.tenant+name))
+		if err != nil || activity.Id != id {
+			continue
+		}
+		actorIds = append(actorIds, activity.Actor.Id)
+		actorIds = append(actorIds, activity.Audience()...)
+		if err := as.rename(c, renames, name, to.Activity+as.tag(id), dryRun); err != nil {
+			return renames, err
+		}
+		if _, err := redis.Int(c.Do("GET", as.tenant+name+referencesSuffix)); err == nil {
+			if err := as.rename(c, renames, name+referencesSuffix, to.Activity+as.tag(id)+referencesSuffix, dryRun); err != nil {
+				return renames, err
+			}
+		}
+		if keyType, err := redis.String(c.Do("TYPE", as.tenant+name+historySuffix)); err == nil && keyType == "list" {
+			if err := as.rename(c, renames, name+historySuffix, to.Activity+as.tag(id)+historySuffix, dryRun); err != nil {
+				return renames, err
+			}
+		}
+	}
+
+	for i := 0; i < len(actorIds); i++ {
+		id := actorIds[i]
+		if id == "" || seen["actor "+id] {
+			continue
+		}
+		seen["actor "+id] = true
+		for _, suffix := range []string{mutedSuffix, blockedSuffix, blockedBySuffix, collectionSuffix} {
+			name := from.Actor + as.tag(id) + suffix
+			if keyType, err := redis.String(c.Do("TYPE", as.tenant+name)); err != nil || keyType != "set" {
+				continue
+			}
+			if suffix == blockedBySuffix {
+				blocking, err := redis.Strings(c.Do("SMEMBERS", as.tenant+name))
+				if err != nil {
+					return renames, err
+				}
+				actorIds = append(actorIds, blocking...)
+			}
+			if err := as.rename(c, renames, name, to.Actor+as.tag(id)+suffix, dryRun); err != nil {
+				return renames, err
+			}
+		}
+	}
+	return renames, nil
+}
+
+// rename renames the key of the tenant from name to newName unless they are equal and adds it to renames. A dry run
+// only adds it.
+func (as *RedisActivityStream) rename(c redis.Conn, renames map[string]string, name, newName string, dryRun bool) error {
+	if newName == "" || newName == name {
+		return nil
+	}
+	key, newKey := as.tenant+name, as.tenant+newName
+	if !dryRun {
+		renamed, err := redis.Bool(c.Do("RENAMENX", key, newKey))
+		if err != nil {
+			return err
+		}
+		if !renamed {
+			return errors.New("migrating " + key + " failed, " + newKey + " exists")
+		}
+	}
+	renames[key] = newKey
+	return nil
+}
+
+// migratedKey returns the name of the key in the Namespace to, or "" if it is no key of the Namespace from. The name
+// is the key without the prefix of the tenant.
+func (as *RedisActivityStream) migratedKey(c redis.Conn, from, to Namespace, key, name, keyType string) (string, error) {
+	switch keyType {
+	case "zset":
+		if strings.HasPrefix(name, from.Stream) {
+			return to.Stream + strings.TrimPrefix(name, from.Stream), nil
+		}
+	case "list":
+		if strings.HasPrefix(name, from.Activity) && strings.HasSuffix(name, historySuffix) {
+			return to.Activity + strings.TrimPrefix(name, from.Activity), nil
+		}
+	case "set":
+		if !strings.HasPrefix(name, from.Actor) {
+			return "", nil
+		}
+		for _, suffix := range []string{mutedSuffix, blockedSuffix, blockedBySuffix, collectionSuffix} {
+			if strings.HasSuffix(name, suffix) {
+				return to.Actor + strings.TrimPrefix(name, from.Actor), nil
+			}
+		}
+	case "string":
+		if !strings.HasPrefix(name, from.Activity) {
+			return "", nil
+		}
+		id := strings.TrimPrefix(name, from.Activity)
+		value, err := c.Do("GET", key)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasSuffix(name, referencesSuffix) {
+			if _, err := redis.Int(value, nil); err == nil {
+				return to.Activity + id, nil
+			}
+		}
+		if activity, err := parseActivityFromResponse(value, nil); err == nil && activity.Id == as.untag(id) {
+			return to.Activity + id, nil
+		}
+	}
+	return "", nil
+}
+
+// belongsTo reports whether the key starts with one of the non-empty prefixes of the Namespace
+func belongsTo(namespace Namespace, key string) bool {
+	for _, prefix := range []string{namespace.Activity, namespace.Stream, namespace.Actor} {
+		if prefix != "" && strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+package redisstream
+
+import (
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+)
+
+func TestNamespace(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	asUnderTest := RedisActivityStream{}
+	asUnderTest.Init(protocol, address)
+	asUnderTest.SetNamespace(NewNamespace("NS_TEST"))
+
+	testStreamID := "NAMESPACE_STREAM_ID"
+	viewerID := "NAMESPACE_VIEWER_ID"
+	testActivity := createTestActivity()
+	mutedActivity := createTestActivity()
+	mutedActivity.Actor.Id = "NAMESPACE_MUTED_ACTOR_ID"
+	cleanUp := func() {
+		removeFromRedis(asUnderTest.streamKey(testStreamID), asUnderTest.pinnedKey(testStreamID), asUnderTest.mutedKey(viewerID))
+		for _, id := range []string{testActivity.Id, mutedActivity.Id} {
+			removeFromRedis(asUnderTest.activityKey(id), asUnderTest.referencesKey(id), asUnderTest.historyKey(id))
+		}
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test prefixed keys", t, func() {
+		cleanUp()
+		So(asUnderTest.AddToStreams(testActivity, testStreamID), ShouldBeEmpty)
+		So(asUnderTest.AddToStreams(mutedActivity, testStreamID), ShouldBeEmpty)
+		So(asUnderTest.Mute(viewerID, mutedActivity.Actor.Id), ShouldBeNil)
+		So(asUnderTest.Pin(testStreamID, testActivity.Id), ShouldBeNil)
+
+		Convey("It should write all keys with their prefix", func() {
+			keyType, err := redis.String(asUnderTest.execute("TYPE", "NS_TEST:act:"+testActivity.Id))
+			So(err, ShouldBeNil)
+			So(keyType, ShouldEqual, "string")
+			keyType, err = redis.String(asUnderTest.execute("TYPE", "NS_TEST:stream:"+testStreamID))
+			So(err, ShouldBeNil)
+			So(keyType, ShouldEqual, "zset")
+			keyType, err = redis.String(asUnderTest.execute("TYPE", "NS_TEST:actor:"+viewerID+"-muted"))
+			So(err, ShouldBeNil)
+			So(keyType, ShouldEqual, "set")
+			exists, err := redis.Bool(asUnderTest.execute("EXISTS", testActivity.Id, testStreamID))
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+		})
+		Convey("It should read activities and streams through their prefix", func() {
+			activity, err := asUnderTest.Get(testActivity.Id)
+			So(err, ShouldBeNil)
+			So(activitiesAreEqual(activity, testActivity), ShouldBeTrue)
+
+			stream, err := asUnderTest.GetStream(testStreamID, 10, 0, activitystream.After)
+			So(err, ShouldBeNil)
+			So(len(stream), ShouldEqual, 2)
+			So(stream[0].Id, ShouldEqual, testActivity.Id)
+			So(stream[0].Pinned, ShouldBeTrue)
+
+			stream, err = asUnderTest.GetStreamForViewer(testStreamID, viewerID, 10, 0, activitystream.After)
+			So(err, ShouldBeNil)
+			So(len(stream), ShouldEqual, 1)
+			So(stream[0].Id, ShouldEqual, testActivity.Id)
+		})
+		Convey("It should update and delete prefixed activities", func() {
+			So(asUnderTest.Update(testActivity, ""), ShouldBeNil)
+			history, err := asUnderTest.History(testActivity.Id)
+			So(err, ShouldBeNil)
+			So(len(history), ShouldEqual, 1)
+
+			So(asUnderTest.Delete(mutedActivity.Id), ShouldBeNil)
+			activity, err := asUnderTest.Get(mutedActivity.Id)
+			So(err, ShouldBeNil)
+			So(activity.IsTombstone(), ShouldBeTrue)
+		})
+	})
+}
+
+func TestMigrateKeys(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	from := NewNamespace("NS_OLD")
+	to := NewNamespace("NS_NEW")
+	oldStream := RedisActivityStream{}
+	oldStream.Init(protocol, address)
+	oldStream.SetNamespace(from)
+	newStream := RedisActivityStream{}
+	newStream.Init(protocol, address)
+	newStream.SetNamespace(to)
+
+	testStreamID := "MIGRATE_STREAM_ID"
+	viewerID := "MIGRATE_VIEWER_ID"
+	followers := "MIGRATE_FOLLOWERS_ID"
+	testActivity := createTestActivity()
+	cleanUp := func() {
+		for _, as := range []RedisActivityStream{oldStream, newStream} {
+			removeFromRedis(as.streamKey(testStreamID), as.pinnedKey(testStreamID), as.mutedKey(viewerID),
+				as.collectionKey(followers), as.activityKey(testActivity.Id), as.referencesKey(testActivity.Id),
+				as.historyKey(testActivity.Id), as.activityKey("MIGRATE_NOT_AN_ACTIVITY"))
+		}
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test migration of keys to another namespace", t, func() {
+		cleanUp()
+		So(oldStream.AddToStreams(testActivity, testStreamID), ShouldBeEmpty)
+		So(oldStream.Update(testActivity, ""), ShouldBeNil)
+		So(oldStream.Pin(testStreamID, testActivity.Id), ShouldBeNil)
+		So(oldStream.Mute(viewerID, "MIGRATE_MUTED_ACTOR_ID"), ShouldBeNil)
+		So(oldStream.AddToCollection(followers, viewerID), ShouldBeNil)
+		_, err := oldStream.execute("SET", oldStream.activityKey("MIGRATE_NOT_AN_ACTIVITY"), "{}")
+		So(err, ShouldBeNil)
+		expected := map[string]string{
+			oldStream.streamKey(testStreamID):        newStream.streamKey(testStreamID),
+			oldStream.pinnedKey(testStreamID):        newStream.pinnedKey(testStreamID),
+			oldStream.mutedKey(viewerID):             newStream.mutedKey(viewerID),
+			oldStream.collectionKey(followers):       newStream.collectionKey(followers),
+			oldStream.activityKey(testActivity.Id):   newStream.activityKey(testActivity.Id),
+			oldStream.referencesKey(testActivity.Id): newStream.referencesKey(testActivity.Id),
+			oldStream.historyKey(testActivity.Id):    newStream.historyKey(testActivity.Id),
+		}
+
+		Convey("When a dry run is done", func() {
+			renames, err := oldStream.MigrateKeys(from, to, true)
+			So(err, ShouldBeNil)
+
+			Convey("It should report the keys to rename without renaming them", func() {
+				for oldKey, newKey := range expected {
+					So(renames[oldKey], ShouldEqual, newKey)
+				}
+				_, err := oldStream.Get(testActivity.Id)
+				So(err, ShouldBeNil)
+			})
+			Convey("It should leave out keys which contain no activity", func() {
+				So(renames, ShouldNotContainKey, oldStream.activityKey("MIGRATE_NOT_AN_ACTIVITY"))
+			})
+		})
+		Convey("When keys are migrated", func() {
+			renames, err := oldStream.MigrateKeys(from, to, false)
+			So(err, ShouldBeNil)
+			for oldKey, newKey := range expected {
+				So(renames[oldKey], ShouldEqual, newKey)
+			}
+
+			Convey("It should read the data from the new namespace", func() {
+				_, err := oldStream.Get(testActivity.Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+
+				stream, err := newStream.GetStreamForViewer(testStreamID, viewerID, 10, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 1)
+				So(stream[0].Id, ShouldEqual, testActivity.Id)
+				So(stream[0].Pinned, ShouldBeTrue)
+
+				history, err := newStream.History(testActivity.Id)
+				So(err, ShouldBeNil)
+				So(len(history), ShouldEqual, 1)
+				muted, err := redis.Bool(newStream.execute("SISMEMBER", newStream.mutedKey(viewerID), "MIGRATE_MUTED_ACTOR_ID"))
+				So(err, ShouldBeNil)
+				So(muted, ShouldBeTrue)
+			})
+			Convey("It should not rename keys again", func() {
+				renames, err := newStream.MigrateKeys(from, to, false)
+				So(err, ShouldBeNil)
+				So(renames, ShouldBeEmpty)
+			})
+		})
+		Convey("When a migrated key exists", func() {
+			_, err := newStream.execute("SET", newStream.activityKey(testActivity.Id), "{}")
+			So(err, ShouldBeNil)
+
+			Convey("It should fail instead of overwriting it", func() {
+				_, err := oldStream.MigrateKeys(from, to, false)
+				So(err, ShouldNotBeNil)
+				value, err := redis.String(newStream.execute("GET", newStream.activityKey(testActivity.Id)))
+				So(err, ShouldBeNil)
+				So(value, ShouldEqual, "{}")
+			})
+		})
+	})
+}
+
+func TestMigrateIds(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	root := RedisActivityStream{}
+	root.Init(protocol, address)
+	// a tenant keeps the keys of the test apart, so that they are deleted along with it
+	asUnderTest := root.forTenant("MIGRATE_DEFAULT")
+	to := NewNamespace("as")
+	migrated := *asUnderTest
+	migrated.SetNamespace(to)
+
+	testStreamID := "MIGRATE_DEFAULT_STREAM_ID"
+	viewerID := "MIGRATE_DEFAULT_VIEWER_ID"
+	testActivity := createTestActivity()
+	testActivity.To = []string{"MIGRATE_DEFAULT_FOLLOWERS_ID"}
+	// keys of another application which look like keys of the default Namespace
+	foreignKeys := map[string][]interface{}{
+		"MIGRATE_FOREIGN_ZSET":    {"ZADD", 1, "FOREIGN_MEMBER"},
+		"OTHERAPP-members":        {"SADD", "FOREIGN_MEMBER"},
+		"OTHERAPP-muted":          {"SADD", "FOREIGN_MEMBER"},
+		"OTHERAPP-history":        {"LPUSH", "FOREIGN_MEMBER"},
+		"OTHERAPP-refs":           {"SET", "1"},
+		testActivity.Id + "-copy": {"SET", "1"},
+	}
+	cleanUp := func() {
+		if _, err := root.DeleteTenant("MIGRATE_DEFAULT"); err != nil {
+			panic(err)
+		}
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test migration of the keys of IDs from the default namespace", t, func() {
+		cleanUp()
+		So(asUnderTest.AddToStreams(testActivity, testStreamID), ShouldBeEmpty)
+		So(asUnderTest.Update(testActivity, ""), ShouldBeNil)
+		So(asUnderTest.Pin(testStreamID, testActivity.Id), ShouldBeNil)
+		So(asUnderTest.AddToCollection("MIGRATE_DEFAULT_FOLLOWERS_ID", viewerID), ShouldBeNil)
+		So(asUnderTest.Mute(viewerID, "MIGRATE_MUTED_ACTOR_ID"), ShouldBeNil)
+		for key, cmd := range foreignKeys {
+			_, err := asUnderTest.execute(cmd[0].(string), append([]interface{}{asUnderTest.tenant + key}, cmd[1:]...)...)
+			So(err, ShouldBeNil)
+		}
+		ids := MigrationIds{Streams: []string{testStreamID}, Actors: []string{viewerID}}
+
+		Convey("When MigrateKeys is used", func() {
+			Convey("It should refuse to scan the default namespace", func() {
+				_, err := asUnderTest.MigrateKeys(Namespace{}, to, true)
+				So(err, ShouldNotBeNil)
+			})
+		})
+		Convey("When a dry run is done", func() {
+			renames, err := asUnderTest.MigrateIds(Namespace{}, to, ids, true)
+			So(err, ShouldBeNil)
+
+			Convey("It should only report the keys of the IDs", func() {
+				So(renames, ShouldResemble, map[string]string{
+					asUnderTest.streamKey(testStreamID):           migrated.streamKey(testStreamID),
+					asUnderTest.pinnedKey(testStreamID):           migrated.pinnedKey(testStreamID),
+					asUnderTest.activityKey(testActivity.Id):      migrated.activityKey(testActivity.Id),
+					asUnderTest.referencesKey(testActivity.Id):    migrated.referencesKey(testActivity.Id),
+					asUnderTest.historyKey(testActivity.Id):       migrated.historyKey(testActivity.Id),
+					asUnderTest.collectionKey(testActivity.To[0]): migrated.collectionKey(testActivity.To[0]),
+					asUnderTest.mutedKey(viewerID):                migrated.mutedKey(viewerID),
+				})
+			})
+		})
+		Convey("When the keys are migrated", func() {
+			_, err := asUnderTest.MigrateIds(Namespace{}, to, ids, false)
+			So(err, ShouldBeNil)
+
+			Convey("It should read the data from the new namespace", func() {
+				stream, err := migrated.GetStreamForViewer(testStreamID, viewerID, 10, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 1)
+				So(stream[0].Pinned, ShouldBeTrue)
+				history, err := migrated.History(testActivity.Id)
+				So(err, ShouldBeNil)
+				So(len(history), ShouldEqual, 1)
+			})
+			Convey("It should not touch the keys of other applications", func() {
+				for key := range foreignKeys {
+					exists, err := redis.Bool(asUnderTest.execute("EXISTS", asUnderTest.tenant+key))
+					So(err, ShouldBeNil)
+					So(exists, ShouldBeTrue)
+				}
+			})
+		})
+	})
+}
@@ -0,0 +1,116 @@
+package redisstream
+
+import (
+	"errors"
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+)
+
+// Option configures a RedisActivityStream created by NewRedisActivityStream and returns an error if its values are
+// invalid
+type Option func(as *RedisActivityStream) error
+
+// NewRedisActivityStream returns a new RedisActivityStream configured by the options and ready to use. Without an
+// option configuring the connection it connects to RedisDefaultURL via RedisDefaultProtocol.
+// It returns an error if an option is invalid, the connection is configured twice or Redis does not reply to PING.
+// The Lua scripts are loaded right away, see LoadScripts.
+func NewRedisActivityStream(opts ...Option) (*RedisActivityStream, error) {
+	as := &RedisActivityStream{
+		maxStreamSize: activitystream.DefaultMaxStreamSize,
+	}
+	for _, opt := range opts {
+		if err := opt(as); err != nil {
+			return nil, err
+		}
+	}
+	if as.cluster != nil && as.replication != nil && len(as.replication.pools) > 0 {
+		return nil, errors.New("invalid options, replicas are not supported on a cluster")
+	}
+	if as.pool == nil && as.cluster == nil {
+		if err := as.InitWithConfig(Config{}); err != nil {
+			return nil, err
+		}
+	}
+
+	c := as.primaryConn()
+	_, err := c.Do("PING")
+	c.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := as.LoadScripts(); err != nil {
+		return nil, err
+	}
+	return as, nil
+}
+
+// WithAddr connects to the Redis at url via protocol, e.g. "tcp" and ":6379"
+func WithAddr(protocol, url string) Option {
+	return func(as *RedisActivityStream) error {
+		if protocol == "" || url == "" {
+			return errors.New("invalid option, protocol and url of Redis must not be empty")
+		}
+		return WithConfig(Config{Protocol: protocol, Addr: url})(as)
+	}
+}
+
+// WithConfig connects to Redis as configured, see Config
+func WithConfig(config Config) Option {
+	return func(as *RedisActivityStream) error {
+		if err := as.connected(); err != nil {
+			return err
+		}
+		return as.InitWithConfig(config)
+	}
+}
+
+// WithPool uses the connection pool, e.g. to share it with the rest of the application. The pool is not closed by the
+// RedisActivityStream.
+func WithPool(pool *redis.Pool) Option {
+	return func(as *RedisActivityStream) error {
+		if pool == nil {
+			return errors.New("invalid option, the pool is nil")
+		}
+		if err := as.connected(); err != nil {
+			return err
+		}
+		as.pool = pool
+		return nil
+	}
+}
+
+// WithCluster connects to the Redis Cluster via protocol, see InitCluster
+func WithCluster(protocol string, addrs ...string) Option {
+	return func(as *RedisActivityStream) error {
+		if err := as.connected(); err != nil {
+			return err
+		}
+		return as.InitCluster(protocol, addrs...)
+	}
+}
+
+// WithClusterConfig connects to the Redis Cluster as configured, see InitClusterWithConfig
+func WithClusterConfig(config Config, addrs ...string) Option {
+	return func(as *RedisActivityStream) error {
+		if err := as.connected(); err != nil {
+			return err
+		}
+		return as.InitClusterWithConfig(config, addrs...)
+	}
+}
+
+// WithMaxStreamSize sets the maximum number of elements of a stream, see SetMaxStreamSize
+func WithMaxStreamSize(maxStreamSize int) Option {
+	return func(as *RedisActivityStream) error {
+		as.SetMaxStreamSize(maxStreamSize)
+		return nil
+	}
+}
+
+// connected returns an error if the connection to Redis has already been configured by another option
+func (as *RedisActivityStream) connected() error {
+	if as.pool != nil || as.cluster != nil {
+		return errors.New("invalid options, the connection to Redis is configured twice")
+	}
+	return nil
+}
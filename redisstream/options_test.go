@@ -0,0 +1,70 @@
+package redisstream
+
+import (
+	"errors"
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	"net"
+	testing "testing"
+	"time"
+)
+
+func TestNewRedisActivityStream(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	testActivity := createTestActivity()
+	defer removeFromRedis(testActivity.Id)
+
+	Convey("Subject: Test creating a RedisActivityStream with options", t, func() {
+		Convey("When a pool of the application is passed", func() {
+			pool := &redis.Pool{
+				MaxIdle: 1,
+				Dial: func() (redis.Conn, error) {
+					return redis.Dial(protocol, address)
+				},
+			}
+			defer pool.Close()
+			asUnderTest, err := NewRedisActivityStream(WithPool(pool), WithMaxStreamSize(5))
+			So(err, ShouldBeNil)
+
+			Convey("It should use the pool", func() {
+				So(asUnderTest.Store(testActivity), ShouldBeNil)
+				So(pool.ActiveCount(), ShouldEqual, 1)
+				So(asUnderTest.maxStreamSize, ShouldEqual, 6)
+			})
+		})
+		Convey("When no connection is configured", func() {
+			asUnderTest, err := NewRedisActivityStream()
+			So(err, ShouldBeNil)
+
+			Convey("It should connect to the default url", func() {
+				So(asUnderTest.Store(testActivity), ShouldBeNil)
+				So(asUnderTest.maxStreamSize, ShouldEqual, activitystream.DefaultMaxStreamSize)
+			})
+		})
+		Convey("When Redis is not reachable", func() {
+			unreachable, _ := net.Listen("tcp", "127.0.0.1:0")
+			unreachable.Close()
+			_, err := NewRedisActivityStream(WithConfig(Config{Addr: unreachable.Addr().String(), DialTimeout: time.Second}))
+
+			Convey("It should return an error", func() {
+				So(errors.Is(err, activitystream.ErrBackendUnavailable), ShouldBeTrue)
+			})
+		})
+		Convey("When options are invalid", func() {
+			_, errEmpty := NewRedisActivityStream(WithAddr("", address))
+			_, errNil := NewRedisActivityStream(WithPool(nil))
+			_, errTwice := NewRedisActivityStream(WithAddr(protocol, address), WithConfig(Config{Addr: address}))
+			_, errConfig := NewRedisActivityStream(WithConfig(Config{DB: -1}))
+
+			Convey("It should return an error", func() {
+				So(errEmpty, ShouldNotBeNil)
+				So(errNil, ShouldNotBeNil)
+				So(errTwice, ShouldNotBeNil)
+				So(errConfig, ShouldNotBeNil)
+			})
+		})
+	})
+}
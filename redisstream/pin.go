@@ -0,0 +1,42 @@
+package redisstream
+
+import (
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	"time"
+)
+
+// pinnedKey returns the key of the sorted set of activity IDs pinned to the stream, scored by time of pinning
+func (as *RedisActivityStream) pinnedKey(streamId string) string {
+	return as.streamKey(streamId) + "-pinned"
+}
+
+// Pin pins the activity to the top of the stream. Pinned activities are returned first on the initial page of GetStream,
+// the last pinned on top, and are left out of all other pages.
+// A pin counts as reference of the activity, so that it is not deleted while pinned, see RetentionPolicy.
+func (as *RedisActivityStream) Pin(streamId, activityId string) error {
+	if err := as.checkKeys(as.pinnedKey(streamId), as.activityKey(activityId)); err != nil {
+		return err
+	}
+	added, err := redis.Int(as.execute("ZADD", as.pinnedKey(streamId), activitystream.MakeTimestamp(time.Now()), activityId))
+	if err != nil || added == 0 {
+		return err
+	}
+	_, err = as.eval(scriptRetainActivity, 1, as.referencesKey(activityId))
+	return err
+}
+
+// Unpin reverts Pin, the activity will appear at its regular position in the stream again.
+// The reference of the pin is released, like those of activities trimmed from the stream.
+func (as *RedisActivityStream) Unpin(streamId, activityId string) error {
+	if err := as.checkKeys(as.pinnedKey(streamId), as.activityKey(activityId)); err != nil {
+		return err
+	}
+	removed, err := redis.Int(as.execute("ZREM", as.pinnedKey(streamId), activityId))
+	if err != nil || removed == 0 {
+		return err
+	}
+	gc := as.trimArgs(streamId)[2]
+	_, err = as.eval(scriptReleaseActivity, 3, as.activityKey(activityId), as.referencesKey(activityId), as.historyKey(activityId), gc)
+	return err
+}
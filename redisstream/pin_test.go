@@ -0,0 +1,107 @@
+package redisstream
+
+import (
+	"github.com/chrisport/go-activitystream/activitystream"
+	. "github.com/smartystreets/goconvey/convey"
+	"strconv"
+	testing "testing"
+	"time"
+)
+
+func TestPinAndUnpin(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	asUnderTest := RedisActivityStream{}
+	asUnderTest.Init(protocol, address)
+
+	testStreamID := "PINNED_STREAM_ID"
+	start := time.Now().UTC()
+	testActivities := make([]activitystream.Activity, 4)
+	for i := range testActivities {
+		testActivities[i] = createTestActivity()
+		testActivities[i].Published = start.Add(time.Duration(i) * time.Millisecond)
+	}
+	announcement := testActivities[1]
+	cleanUp := func() {
+		removeFromRedis(testStreamID, asUnderTest.pinnedKey(testStreamID))
+		for i := range testActivities {
+			removeFromRedis(testActivities[i].Id, asUnderTest.referencesKey(testActivities[i].Id))
+		}
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test Pin and Unpin", t, func() {
+		cleanUp()
+		for i := range testActivities {
+			errs := asUnderTest.AddToStreams(testActivities[i], testStreamID)
+			So(errs, ShouldBeEmpty)
+		}
+
+		Convey("When an older activity is pinned to the stream", func() {
+			err := asUnderTest.Pin(testStreamID, announcement.Id)
+			So(err, ShouldBeNil)
+
+			Convey("It should be returned first on the initial page without counting to its size", func() {
+				stream, err := asUnderTest.GetStream(testStreamID, 2, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 3)
+				So(stream[0].Id, ShouldEqual, announcement.Id)
+				So(stream[0].Pinned, ShouldBeTrue)
+				So(stream[1].Id, ShouldEqual, testActivities[3].Id)
+				So(stream[1].Pinned, ShouldBeFalse)
+				So(stream[2].Id, ShouldEqual, testActivities[2].Id)
+			})
+			Convey("It should be left out of the following pages", func() {
+				stream, err := asUnderTest.GetStream(testStreamID, 2, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				_, next := activitystream.CreateTokens(2, activitystream.After, stream)
+				So(next, ShouldEqual, "?s=2&after="+strconv.Itoa(testActivities[2].Score()))
+
+				stream, err = asUnderTest.GetStream(testStreamID, 2, testActivities[2].Score(), activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 1)
+				So(stream[0].Id, ShouldEqual, testActivities[0].Id)
+
+				stream, err = asUnderTest.GetStream(testStreamID, 2, testActivities[0].Score(), activitystream.Before)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 2)
+				So(stream[0].Id, ShouldEqual, testActivities[3].Id)
+				So(stream[1].Id, ShouldEqual, testActivities[2].Id)
+			})
+			Convey("It should not store the pinned flag", func() {
+				activity, err := asUnderTest.Get(announcement.Id)
+				So(err, ShouldBeNil)
+				So(activity.Pinned, ShouldBeFalse)
+			})
+			Convey("It should be at its regular position after Unpin", func() {
+				err := asUnderTest.Unpin(testStreamID, announcement.Id)
+				So(err, ShouldBeNil)
+
+				stream, err := asUnderTest.GetStream(testStreamID, 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 4)
+				So(stream[2].Id, ShouldEqual, announcement.Id)
+				So(stream[2].Pinned, ShouldBeFalse)
+			})
+		})
+		Convey("When a pinned activity is trimmed from a stream which collects garbage", func() {
+			asUnderTest.SetRetentionPolicies(activitystream.RetentionPolicy{Pattern: testStreamID, MaxSize: 2, CollectGarbage: true})
+			defer asUnderTest.SetRetentionPolicies()
+			So(asUnderTest.Pin(testStreamID, announcement.Id), ShouldBeNil)
+			_, err := asUnderTest.Trim(testStreamID)
+			So(err, ShouldBeNil)
+
+			Convey("It should be kept until it is unpinned", func() {
+				_, err := asUnderTest.Get(announcement.Id)
+				So(err, ShouldBeNil)
+				_, err = asUnderTest.Get(testActivities[0].Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+
+				So(asUnderTest.Unpin(testStreamID, announcement.Id), ShouldBeNil)
+				_, err = asUnderTest.Get(announcement.Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+			})
+		})
+	})
+}
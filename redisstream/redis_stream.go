@@ -15,39 +15,33 @@ const (
 	RedisDefaultProtocol = "tcp"
 	// RedisDefaultURL is the default url used to connect to Redis, in case no other is specified.
 	RedisDefaultURL = ":6379"
-
-	luaResolveStreamSetAll = `local ids=redis.call("ZREVRANGE",KEYS[1],0,ARGV[1])
-	if table.getn(ids)==0 then return {} end
-	return redis.call("MGET",unpack(ids))`
-	// AFTER:  ZREVRANGEBYSCORE 5444ccbae3c1290013000004-out 1421679584 -inf LIMIT 1 2
-	luaResolveStreamSetAfter = `local ids=redis.call("ZREVRANGEBYSCORE",KEYS[1],ARGV[1],"-inf","LIMIT",1,ARGV[2])
-	if table.getn(ids)==0 then return {} end
-	return redis.call("MGET",unpack(ids))`
-	// BEFORE: ZRANGEBYSCORE 5444ccbae3c1290013000004-out 1421679584 +inf LIMIT 1 2
-	luaResolveStreamSetBefore = `local ids=redis.call("ZRANGEBYSCORE",KEYS[1],ARGV[1],"+inf","LIMIT",1,ARGV[2])
-	if table.getn(ids)==0 then return {} end
-	return redis.call("MGET",unpack(ids))`
 )
 
-// NewRedisActivityStream returns a new RedisActivityStream, ready to use.
-func NewRedisActivityStream(protocol, url string) activitystream.ActivityStream {
-	as := RedisActivityStream{
-		maxStreamSize: activitystream.DefaultMaxStreamSize,
-	}
-	as.Init(protocol, url)
-	return &as
-}
-
 // RedisActivityStream is an implementation of ActivityStream using Redis.
 type RedisActivityStream struct {
-	pool          *redis.Pool
-	maxStreamSize int
+	pool           *redis.Pool
+	maxStreamSize  int
+	scorer         activitystream.Scorer
+	validator      activitystream.Validator
+	useMGET        bool
+	resolver       activitystream.Resolver
+	tombstoneTTL   time.Duration
+	maxHistorySize int
+	retention      activitystream.RetentionPolicies
+	keys           Namespace
+	tenant         string
+	limits         activitystream.TenantLimits
+	tenantLimits   map[string]activitystream.TenantLimits
+	cluster        *cluster
+	replication    *replication
+	callerId       string
 }
 
 // SetMaxStreamSize will set the maximum number of elements of a stream to the specified number.
 // A negative number means there is no limit, the streams will keep growing.
+// The maximum size of single streams can be set through RetentionPolicy.MaxSize, see SetRetentionPolicies.
 // Important: Decreasing this number will
-// 		1. not affect existing streams unless a new element is added.
+// 		1. not affect existing streams unless a new element is added or they are trimmed through Trim.
 // 		2. by adding a new element to an existing stream, the stream will be cut down to the new maximum
 func (as *RedisActivityStream) SetMaxStreamSize(maxStreamSize int) {
 	if maxStreamSize == 0 {
@@ -56,9 +50,46 @@ func (as *RedisActivityStream) SetMaxStreamSize(maxStreamSize int) {
 	as.maxStreamSize = maxStreamSize + 1
 }
 
+// SetScorer sets the Scorer used to sort activities within their streams, nil restores the default TimeScorer.
+// Important: Changing the Scorer will not affect existing streams unless they are rescored through Rescore.
+func (as *RedisActivityStream) SetScorer(scorer activitystream.Scorer) {
+	as.scorer = scorer
+}
+
+// SetValidator sets the rules activities must follow to be stored by Store and AddToStreams, nil disables
+// validation, which is the default.
+func (as *RedisActivityStream) SetValidator(validator activitystream.Validator) {
+	as.validator = validator
+}
+
+// SetUseMGET sets whether BulkGet and BulkGetResults retrieve all activities through a single MGET command instead
+// of one pipelined GET per activity, on a cluster one MGET per slot is sent. With MGET a key which holds no string is
+// reported as ErrNotFound, with GET as the error replied by Redis.
+func (as *RedisActivityStream) SetUseMGET(useMGET bool) {
+	as.useMGET = useMGET
+}
+
+// SetResolver sets the Resolver used to replace actor, object and target of activities by their current version
+// when they are read, nil disables hydration, which is the default. See activitystream.Hydrate.
+// Use activitystream.CachedResolver to avoid a lookup on every read.
+func (as *RedisActivityStream) SetResolver(resolver activitystream.Resolver) {
+	as.resolver = resolver
+}
+
+// score returns the score of the activity within the stream given by the Scorer of the RedisActivityStream
+func (as *RedisActivityStream) score(streamId string, activity activitystream.Activity) int64 {
+	if as.scorer == nil {
+		return activitystream.TimeScorer.Score(streamId, activity)
+	}
+	return as.scorer.Score(streamId, activity)
+}
+
 // Init initializes the RedisActivityStream, it takes exactly two arguments:
 //	protocol		the protocol to connect to redis, "tcp" by default
 //	url		the url of redis including the port, ":6379" by default
+//
+// Deprecated: Init prints invalid arguments and falls back to the defaults, use NewRedisActivityStream or
+// InitWithConfig, which return an error instead.
 func (as *RedisActivityStream) Init(args ...string) {
 	if len(args) != 2 {
 		args = []string{RedisDefaultProtocol, RedisDefaultURL}
@@ -76,26 +107,12 @@ func (as *RedisActivityStream) Init(args ...string) {
 	if as.maxStreamSize == 0 {
 		as.maxStreamSize = activitystream.DefaultMaxStreamSize
 	}
-	values := []string{args[0], args[1]}
-	dialFunc := func() (c redis.Conn, err error) {
-		c, err = redis.Dial(values[0], values[1])
-		return
-	}
-
-	// initialize a new pool
-	as.pool = &redis.Pool{
-		MaxIdle:     3,
-		IdleTimeout: 180 * time.Second,
-		Dial:        dialFunc,
-		TestOnBorrow: func(c redis.Conn, t time.Time) error {
-			_, err := c.Do("PING")
-			return err
-		},
-	}
+	// the configuration is valid, as it contains no more than the protocol and url
+	as.pool, _ = Config{Protocol: args[0], Addr: args[1]}.newPool()
 }
 
 func (as *RedisActivityStream) execute(cmd string, args ...interface{}) (result interface{}, err error) {
-	c := as.pool.Get()
+	c := as.conn()
 	defer c.Close()
 
 	return c.Do(cmd, args...)
@@ -105,160 +122,346 @@ func (as *RedisActivityStream) execute(cmd string, args ...interface{}) (result
 // The stream is identified by its ID.
 // Pagination is provided as follow:
 //	size		the size of the page
-//	pivotTime		the last received unix time in millisecond, used for identifying page start
+//	pivotTime		the score of the last received activity, by default its unix time in millisecond
 //	direction	the direction from pivotTime, the page starts either After the pivot or Before the pivot
 func (as *RedisActivityStream) GetStream(streamId string, size int, pivotTime int, afterNotBefore activitystream.Direction) ([]activitystream.Activity, error) {
-	var raw interface{}
-	var err error
-	if pivotTime == 0 {
-		raw, err = as.execute("eval", luaResolveStreamSetAll, 1, streamId, size-1)
-	} else if afterNotBefore == activitystream.After {
-		// AFTER:  ZREVRANGEBYSCORE
-		raw, err = as.execute("eval", luaResolveStreamSetAfter, 1, streamId, pivotTime, size)
-	} else {
-		// BEFORE: ZRANGEBYSCORE
-		raw, err = as.execute("eval", luaResolveStreamSetBefore, 1, streamId, pivotTime, size)
+	if err := as.checkKeys(as.streamKey(streamId)); err != nil {
+		return nil, err
 	}
+	return as.resolveStream([]string{as.streamKey(streamId), as.pinnedKey(streamId)}, size, pivotTime, afterNotBefore)
+}
 
-	if err != nil {
+// GetStreamForViewer returns the same page as GetStream, but leaves out activities which are not visible to the viewer:
+//	activities of actors the viewer has muted or blocked and of actors who have blocked the viewer
+//	activities with an audience which does not address the viewer, see Activity.IsVisibleTo
+// Left out activities do not reduce the size of the page. An empty viewerId stands for an anonymous viewer.
+// The blind recipients Bto and Bcc are only returned to the actor of an activity.
+func (as *RedisActivityStream) GetStreamForViewer(streamId, viewerId string, size int, pivotTime int, afterNotBefore activitystream.Direction) ([]activitystream.Activity, error) {
+	keys := []string{as.streamKey(streamId), as.pinnedKey(streamId)}
+	if viewerId != "" {
+		keys = append(keys, as.mutedKey(viewerId), as.blockedKey(viewerId), as.blockedByKey(viewerId))
+	}
+	if err := as.checkKeys(keys...); err != nil {
 		return nil, err
 	}
-
-	reply, ok := raw.([]interface{})
-	if !ok {
-		rawType := reflect.TypeOf(raw)
-		return nil, errors.New("Redis response was invalid. Response was of type " + rawType.String())
+	activities, err := as.resolveStream(keys, size, pivotTime, afterNotBefore, viewerId, collectionSuffix)
+	if err != nil {
+		return nil, err
 	}
-	if afterNotBefore == activitystream.Before {
-		// reverse the array since we used original order from Redis for before-request (oldest->newest)
-		for i, j := 0, len(reply)-1; i < j; i, j = i+1, j-1 {
-			reply[i], reply[j] = reply[j], reply[i]
+	for i := range activities {
+		if viewerId == "" || activities[i].Actor.Id != viewerId {
+			activities[i].Bto = nil
+			activities[i].Bcc = nil
 		}
 	}
+	return activities, nil
+}
 
-	activities := make([]activitystream.Activity, 0)
-	for i := range reply {
-		activity, err := parseActivityFromResponse(reply[i], err)
+// resolveStream executes the resolution script with the direction of the pagination on the stream keys[0] with its pinned
+// activities keys[1]. All further keys are sets of actor IDs whose activities are left out.
+// The optional viewer arguments are the viewer ID and the suffix of collection keys.
+func (as *RedisActivityStream) resolveStream(keys []string, size int, pivotTime int, afterNotBefore activitystream.Direction, viewer ...interface{}) ([]activitystream.Activity, error) {
+	var direction string
+	switch {
+	case pivotTime == 0:
+		direction = ""
+	case afterNotBefore == activitystream.After:
+		// AFTER:  ZREVRANGEBYSCORE
+		direction = "after"
+	default:
+		// BEFORE: ZRANGEBYSCORE
+		direction = "before"
+	}
+
+	var pinned, unpinned []interface{}
+	if as.cluster != nil {
+		var err error
+		if pinned, unpinned, err = as.resolveStreamOnCluster(keys, size, pivotTime, afterNotBefore, viewer...); err != nil {
+			return nil, err
+		}
+	} else {
+		args := []interface{}{len(keys)}
+		for i := range keys {
+			args = append(args, keys[i])
+		}
+		args = append(args, size, pivotTime, as.activityKey(""), as.actorKey(""), direction)
+		raw, err := as.evalRead(scriptResolve, append(args, viewer...)...)
 		if err != nil {
-			continue
+			return nil, err
 		}
 
-		activities = append(activities, activity)
+		reply, ok := raw.([]interface{})
+		if !ok || len(reply) != 2 {
+			rawType := reflect.TypeOf(raw)
+			return nil, errors.New("Redis response was invalid. Response was of type " + rawType.String())
+		}
+		pinned, _ = reply[0].([]interface{})
+		unpinned, _ = reply[1].([]interface{})
+	}
+	if afterNotBefore == activitystream.Before {
+		// reverse the array since we used original order from Redis for before-request (oldest->newest)
+		for i, j := 0, len(unpinned)-1; i < j; i, j = i+1, j-1 {
+			unpinned[i], unpinned[j] = unpinned[j], unpinned[i]
+		}
 	}
 
+	activities := parseActivitiesFromResponse(pinned)
+	for i := range activities {
+		activities[i].Pinned = true
+	}
+	activities = append(activities, parseActivitiesFromResponse(unpinned)...)
+	if err := activitystream.Hydrate(as.resolver, activities); err != nil {
+		return nil, err
+	}
 	return activities, nil
 }
 
-// BulkGet returns an array of Activity by their IDs
+// BulkGet returns an array of Activity by their IDs. Activities which are missing or invalid are left out, use
+// BulkGetResults to find out which.
 func (as *RedisActivityStream) BulkGet(ids ...string) ([]activitystream.Activity, error) {
-	c := as.pool.Get()
-	defer c.Close()
-
-	for i := range ids {
-		c.Send("GET", ids[i])
+	results, err := as.BulkGetResults(ids...)
+	if err != nil {
+		return nil, err
 	}
-	c.Flush()
 
-	errs := make([]error, 0)
 	activities := make([]activitystream.Activity, 0)
-	for _ = range ids {
-		v, err := c.Receive()
+	for i := range results {
+		if results[i].Err == nil {
+			activities = append(activities, results[i].Activity)
+		}
+	}
+	return activities, nil
+}
+
+// BulkGetResults returns the activities of the IDs, the result at a position belongs to the ID at the same position
+// and contains either the activity or the error retrieving it. The error is only returned if no activity could be
+// retrieved at all, e.g. because Redis is unavailable.
+// The activities are retrieved through pipelined GET commands, or a single MGET if enabled through SetUseMGET.
+func (as *RedisActivityStream) BulkGetResults(ids ...string) ([]activitystream.BulkResult, error) {
+	results := make([]activitystream.BulkResult, len(ids))
+	if len(ids) == 0 {
+		return results, nil
+	}
+	for i := range ids {
+		if err := as.checkKeys(as.activityKey(ids[i])); err != nil {
+			return nil, err
+		}
+	}
+	c := as.readConn()
+	defer c.Close()
 
-		activity, err := parseActivityFromResponse(v, err)
+	replies := make([]interface{}, len(ids))
+	errs := make([]error, len(ids))
+	if as.useMGET {
+		keys := make([]string, len(ids))
+		for i := range ids {
+			keys[i] = as.activityKey(ids[i])
+		}
+		values, err := as.mget(c, keys)
 		if err != nil {
-			errs = append(errs, err)
-			continue
+			return nil, err
+		}
+		copy(replies, values)
+	} else {
+		for i := range ids {
+			c.Send("GET", as.activityKey(ids[i]))
+		}
+		if err := c.Flush(); err != nil {
+			return nil, err
 		}
+		for i := range ids {
+			replies[i], errs[i] = c.Receive()
+			if errors.Is(errs[i], activitystream.ErrBackendUnavailable) {
+				return nil, errs[i]
+			}
+		}
+	}
 
-		activities = append(activities, activity)
+	activities := make([]activitystream.Activity, 0, len(ids))
+	for i := range ids {
+		results[i].Id = ids[i]
+		results[i].Activity, results[i].Err = parseActivityFromResponse(replies[i], errs[i])
+		if results[i].Err == nil {
+			activities = append(activities, results[i].Activity)
+		}
 	}
 
-	return activities, nil
+	if as.resolver != nil {
+		if err := activitystream.Hydrate(as.resolver, activities); err != nil {
+			return nil, err
+		}
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].Activity, activities = activities[0], activities[1:]
+			}
+		}
+	}
+	return results, nil
 }
 
 // Get returns a single Activity by its ID
 func (as *RedisActivityStream) Get(id string) (activity activitystream.Activity, err error) {
-	resp, err := as.execute("GET", id)
-	return parseActivityFromResponse(resp, err)
+	if err := as.checkKeys(as.activityKey(id)); err != nil {
+		return activity, err
+	}
+	resp, err := as.read("GET", as.activityKey(id))
+	activity, err = parseActivityFromResponse(resp, err)
+	if err != nil || as.resolver == nil {
+		return activity, err
+	}
+	activities := []activitystream.Activity{activity}
+	if err = activitystream.Hydrate(as.resolver, activities); err != nil {
+		return activitystream.Activity{}, err
+	}
+	return activities[0], nil
 }
 
 // Store stores a single Activity in the database
-// This method is idempotent since the Activity is identified by its ID.
+// This method is idempotent since the Activity is identified by its ID. An existing activity is replaced like by
+// Update, but without comparing its version, so that clients holding the replaced version get ErrVersionConflict.
+// If a Validator is set, invalid activities are rejected with activitystream.ValidationErrors.
+// Deleted activities can not be stored again until their tombstone has expired, ErrInvalidActivity is returned for them.
 func (as *RedisActivityStream) Store(activity activitystream.Activity) error {
+	if err := as.checkKeys(as.activityKey(activity.Id)); err != nil {
+		return err
+	}
 	if activity.Published.Unix() <= 0 {
 		activity.Published = time.Now().UTC()
 	}
+	if err := as.validator.Validate(&activity); err != nil {
+		return err
+	}
+	activity.Pinned = false
+	// the script sets the version, an existing activity is stored with its next revision like by Update
+	version := activity.Version
+	activity.Version = ""
 	a, err := json.Marshal(activity)
 	if err != nil {
-		return errors.New("marshalling Activity failed, " + err.Error())
+		return activitystream.Wrap(activitystream.ErrInvalidActivity, errors.New("marshalling Activity failed, "+err.Error()))
+	}
+	// keep the TTL set by AddToStreams, which expires along with the references
+	stored, err := redis.Bool(as.eval(scriptUpdateActivity, 2, as.activityKey(activity.Id), as.historyKey(activity.Id), "", a, as.historySize(), "1", version))
+	if err == nil && !stored {
+		return activitystream.Wrap(activitystream.ErrInvalidActivity, errors.New("activity "+activity.Id+" has been deleted"))
 	}
-	_, err = as.execute("SET", activity.Id, a)
 	return err
 }
 
 // AddToStreams adds a certain activity to one or more streams. The streams are identified by their IDs
 // Important: This will also write the activity to database, a call to the method 'Store' would be unnecessary but have no effect.
+// If a Validator is set, invalid activities are not added to any stream.
+// For every stream the activity has been trimmed from right away, the returned errors contain ErrStreamFull, or
+// ErrExpired if it is older than the MaxAge of the RetentionPolicy of the stream.
 func (as *RedisActivityStream) AddToStreams(activity activitystream.Activity, streamIds ...string) []error {
-	resp, err := as.execute("EXISTS", activity.Id)
-	if v, ok := resp.(int64); err != nil || (ok && v == 0) {
-		err := as.Store(activity)
-		if err != nil {
-			return []error{err}
-		}
+	keys := []string{as.activityKey(activity.Id)}
+	for i := range streamIds {
+		keys = append(keys, as.streamKey(streamIds[i]))
 	}
-
-	c := as.pool.Get()
-	defer c.Close()
-	score := activitystream.MakeTimestamp(activity.Published)
-	if score <= 0 {
+	if err := as.checkKeys(keys...); err != nil {
+		return []error{err}
+	}
+	if activity.Published.Unix() <= 0 {
 		activity.Published = time.Now().UTC()
-		score = activitystream.MakeTimestamp(time.Now().UTC())
+	}
+	if err := as.validator.Validate(&activity); err != nil {
+		return []error{err}
+	}
+	activity.Pinned = false
+	a, err := json.Marshal(activity)
+	if err != nil {
+		return []error{activitystream.Wrap(activitystream.ErrInvalidActivity, errors.New("marshalling Activity failed, "+err.Error()))}
 	}
 
-	idHex := activity.Id
-	for i := range streamIds {
-		c.Send("ZADD", streamIds[i], score, idHex)
-		if as.maxStreamSize > 0 {
-			c.Send("ZREMRANGEBYRANK", streamIds[i], 0, -as.maxStreamSize)
+	var status []int
+	if as.cluster != nil {
+		status, err = as.addToStreamsOnCluster(activity, a, streamIds)
+	} else {
+		args := []interface{}{len(streamIds) + 2, as.activityKey(activity.Id), as.referencesKey(activity.Id)}
+		for i := range streamIds {
+			args = append(args, as.streamKey(streamIds[i]))
+		}
+		args = append(args, a, int64(as.activityTTL(streamIds)/time.Millisecond), referencesSuffix, historySuffix,
+			activity.Id, as.activityKey(""))
+		for i := range streamIds {
+			args = append(args, as.score(streamIds[i], activity))
+			args = append(args, as.trimArgs(streamIds[i])...)
 		}
+		status, err = redis.Ints(as.eval(scriptAddToStreams, args...))
 	}
-	c.Flush()
-
-	k := len(streamIds)
-	if as.maxStreamSize > 0 {
-		k = len(streamIds) * 2
+	if err != nil {
+		return []error{err}
 	}
 
 	errs := make([]error, 0)
-	for ; k > 0; k-- {
-		if _, err := c.Receive(); err != nil {
-			errs = append(errs, err)
+	for i := range streamIds {
+		switch status[2*i] {
+		case 1:
+			errs = append(errs, activitystream.Wrap(activitystream.ErrStreamFull, errors.New("stream "+streamIds[i])))
+		case 2:
+			errs = append(errs, activitystream.Wrap(activitystream.ErrExpired, errors.New("stream "+streamIds[i])))
 		}
 	}
 	return errs
 }
 
+// Rescore recomputes the scores of all activities in a stream with the current Scorer.
+// Activities which are missing in the database keep their score.
+func (as *RedisActivityStream) Rescore(streamId string) error {
+	if err := as.checkKeys(as.streamKey(streamId)); err != nil {
+		return err
+	}
+	ids, err := redis.Strings(as.execute("ZRANGE", as.streamKey(streamId), 0, -1))
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	activities, err := as.BulkGet(ids...)
+	if err != nil || len(activities) == 0 {
+		return err
+	}
+
+	args := []interface{}{as.streamKey(streamId), "XX"}
+	for i := range activities {
+		args = append(args, as.score(streamId, activities[i]), activities[i].Id)
+	}
+	_, err = as.execute("ZADD", args...)
+	return err
+}
+
+// parseActivitiesFromResponse parses all items of a Redis array reply, items which are no valid Activity are left out
+func parseActivitiesFromResponse(reply []interface{}) []activitystream.Activity {
+	activities := make([]activitystream.Activity, 0)
+	for i := range reply {
+		activity, err := parseActivityFromResponse(reply[i], nil)
+		if err != nil {
+			continue
+		}
+
+		activities = append(activities, activity)
+	}
+	return activities
+}
+
 func parseActivityFromResponse(resp interface{}, respErr error) (activity activitystream.Activity, err error) {
 	if respErr != nil {
 		return activity, respErr
 	}
 	if resp == nil {
-		err = redis.ErrNil
+		err = activitystream.ErrNotFound
 		return
 	}
 
 	val, ok := resp.([]byte)
 	if !ok {
-		err = errors.New("item from redis is not a byte array")
+		err = activitystream.Wrap(activitystream.ErrInvalidActivity, errors.New("item from redis is not a byte array"))
 		return
 	}
 
 	err = json.Unmarshal(val, &activity)
 	if err != nil {
-		err = errors.New("unmarshall Activity failed, " + err.Error())
+		err = activitystream.Wrap(activitystream.ErrInvalidActivity, errors.New("unmarshall Activity failed, "+err.Error()))
 		return
 	} else if activity.Id == "" {
-		err = errors.New("object was not valid activity")
+		err = activitystream.Wrap(activitystream.ErrInvalidActivity, errors.New("object was not valid activity"))
 		return
 	}
 	return
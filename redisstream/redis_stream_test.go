@@ -1,6 +1,8 @@
 package redisstream
 
 import (
+	"errors"
+	"fmt"
 	"github.com/chrisport/go-activitystream/activitystream"
 	redis "github.com/garyburd/redigo/redis"
 	. "github.com/smartystreets/goconvey/convey"
@@ -24,7 +26,8 @@ func TestCreateRedisActivitystream(t *testing.T) {
 
 	Convey("Subject: Test Creating new RedisActivitystream", t, func() {
 		Convey("When acitivitystream is retrieved through NewRedisActivitStream method", func() {
-			asUnderTest := NewRedisActivityStream(protocol, address)
+			asUnderTest, err := NewRedisActivityStream(WithAddr(protocol, address))
+			So(err, ShouldBeNil)
 
 			Convey("It should be ready to Store and Get an activity", func() {
 				defer removeFromRedis(testActivity.Id)
@@ -118,6 +121,41 @@ func TestStoreAndGet(t *testing.T) {
 				})
 			})
 
+			Convey("When activity with optional objects, tags and extensions is written", func() {
+				richActivity := createTestActivity()
+				updated := richActivity.Published.Add(time.Minute)
+				richActivity.Updated = &updated
+				richActivity.Context = &activitystream.BaseObject{Id: "CONVERSATION_ID", ObjectType: "conversation"}
+				richActivity.Result = &activitystream.BaseObject{Id: "RESULT_ID"}
+				richActivity.Location = &activitystream.BaseObject{DisplayName: "Zurich", ObjectType: "place"}
+				richActivity.Tags = []activitystream.BaseObject{{Id: "FRIEND_ID", ObjectType: activitystream.Mention}}
+				richActivity.Extensions = activitystream.Extensions{}
+				So(richActivity.Extensions.Set("rating", map[string]interface{}{"stars": 4, "tags": []string{"a", "b"}}), ShouldBeNil)
+				defer removeFromRedis(richActivity.Id)
+				So(asUnderTest.Store(richActivity), ShouldBeNil)
+
+				Convey("It should be returned without loss", func() {
+					res, err := asUnderTest.Get(richActivity.Id)
+					So(err, ShouldBeNil)
+					So(res.Updated.Equal(updated), ShouldBeTrue)
+					So(res.Context, ShouldResemble, richActivity.Context)
+					So(res.Result, ShouldResemble, richActivity.Result)
+					So(res.Location, ShouldResemble, richActivity.Location)
+					So(res.Generator, ShouldBeNil)
+					So(res.Mentions(), ShouldResemble, richActivity.Tags)
+
+					var rating struct {
+						Stars int
+						Tags  []string
+					}
+					ok, err := res.Extensions.Get("rating", &rating)
+					So(ok, ShouldBeTrue)
+					So(err, ShouldBeNil)
+					So(rating.Stars, ShouldEqual, 4)
+					So(rating.Tags, ShouldResemble, []string{"a", "b"})
+				})
+			})
+
 			Convey("When String is written to a key and Get called on this key", func() {
 				_, err := asUnderTest.execute("SET", "SOME_KEY", "NOT_AN_ACTIVITY")
 				So(err, ShouldBeNil)
@@ -133,7 +171,7 @@ func TestStoreAndGet(t *testing.T) {
 			Convey("When Get called on inexistent key", func() {
 				Convey("It should return error", func() {
 					_, err := asUnderTest.Get("SOME_INEXISTENT_KEY")
-					So(err, ShouldEqual, redis.ErrNil)
+					So(err, ShouldEqual, activitystream.ErrNotFound)
 				})
 			})
 		})
@@ -176,6 +214,41 @@ func TestBulkGet(t *testing.T) {
 				}
 
 			})
+
+			Convey("When some IDs are missing or invalid", func() {
+				defer removeFromRedis("SOME_KEY", "SOME_SET")
+				_, err := asUnderTest.execute("SET", "SOME_KEY", "NOT_AN_ACTIVITY")
+				So(err, ShouldBeNil)
+				_, err = asUnderTest.execute("SADD", "SOME_SET", "MEMBER")
+				So(err, ShouldBeNil)
+				requested := []string{ids[0], "SOME_INEXISTENT_KEY", ids[1], "SOME_KEY", "SOME_SET"}
+
+				for _, useMGET := range []bool{false, true} {
+					asUnderTest.SetUseMGET(useMGET)
+					results, err := asUnderTest.BulkGetResults(requested...)
+					activities, bulkErr := asUnderTest.BulkGet(requested...)
+					asUnderTest.SetUseMGET(false)
+
+					Convey(fmt.Sprintf("It should report a result for every ID in order (MGET: %t)", useMGET), func() {
+						So(err, ShouldBeNil)
+						So(len(results), ShouldEqual, len(requested))
+						for i := range results {
+							So(results[i].Id, ShouldEqual, requested[i])
+						}
+						So(results[0].Err, ShouldBeNil)
+						So(activitiesAreEqual(results[0].Activity, testActivity[0]), ShouldBeTrue)
+						So(results[1].Err, ShouldEqual, activitystream.ErrNotFound)
+						So(results[2].Err, ShouldBeNil)
+						So(activitiesAreEqual(results[2].Activity, testActivity[1]), ShouldBeTrue)
+						So(errors.Is(results[3].Err, activitystream.ErrInvalidActivity), ShouldBeTrue)
+						So(results[4].Err, ShouldNotBeNil)
+					})
+					Convey(fmt.Sprintf("It should leave them out in BulkGet (MGET: %t)", useMGET), func() {
+						So(bulkErr, ShouldBeNil)
+						So(len(activities), ShouldEqual, 2)
+					})
+				}
+			})
 		})
 	})
 }
@@ -193,7 +266,7 @@ func TestStoreAndGetIDsFromStream(t *testing.T) {
 	Convey("Subject: Test AddToStreams and GetStream ", t, func() {
 		//Precondition
 		_, err := asUnderTest.Get(testActivity.Id)
-		So(err, ShouldEqual, redis.ErrNil)
+		So(err, ShouldEqual, activitystream.ErrNotFound)
 
 		Convey("When activity is written to streams", func() {
 			errs := asUnderTest.AddToStreams(testActivity, testIDs...)
@@ -252,6 +325,30 @@ func TestAddToStreams(t *testing.T) {
 
 			})
 		})
+		Convey("When an invalid activity is written and a Validator has been set", func() {
+			asUnderTest.SetValidator(activitystream.DefaultValidator)
+			defer asUnderTest.SetValidator(nil)
+			defer removeFromRedis(testStreamID)
+			invalidActivity := createTestActivity()
+			invalidActivity.Verb = ""
+			defer removeFromRedis(invalidActivity.Id)
+
+			errs := asUnderTest.AddToStreams(invalidActivity, testStreamID)
+
+			Convey("It should be rejected with the violated rules", func() {
+				So(len(errs), ShouldEqual, 1)
+				validationErrs, ok := errs[0].(activitystream.ValidationErrors)
+				So(ok, ShouldBeTrue)
+				So(validationErrs[0].Path, ShouldEqual, "verb")
+				So(asUnderTest.Store(invalidActivity), ShouldNotBeNil)
+
+				_, err := asUnderTest.Get(invalidActivity.Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+				stream, err := asUnderTest.GetStream(testStreamID, 99, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 0)
+			})
+		})
 		Convey("When 150 IDs are written to a stream and Max stream size has been set to 40", func() {
 			asUnderTest.SetMaxStreamSize(40)
 			defer asUnderTest.SetMaxStreamSize(50)
@@ -412,6 +509,121 @@ func TestGetStreamEdgeCases(t *testing.T) {
 	})
 }
 
+func TestScorer(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	asUnderTest := RedisActivityStream{}
+	asUnderTest.Init(protocol, address)
+
+	testStreamID := "RANKED_STREAM_ID"
+	oldActivity := createTestActivity()
+	oldActivity.Published = time.Now().UTC().Add(-time.Minute)
+	oldActivity.Actor.Id = "BEST_FRIEND_ID"
+	newActivity := createTestActivity()
+	defer removeFromRedis(testStreamID, oldActivity.Id, newActivity.Id)
+
+	Convey("Subject: Test streams ranked by a Scorer", t, func() {
+		removeFromRedis(testStreamID, oldActivity.Id, newActivity.Id)
+		scorer := activitystream.DecayScorer{
+			HalfLife: time.Hour,
+			Affinity: func(streamId string, a activitystream.Activity) float64 {
+				if a.Actor.Id == "BEST_FRIEND_ID" {
+					return 2
+				}
+				return 1
+			},
+		}
+		asUnderTest.SetScorer(scorer)
+		defer asUnderTest.SetScorer(nil)
+
+		So(asUnderTest.AddToStreams(oldActivity, testStreamID), ShouldBeEmpty)
+		So(asUnderTest.AddToStreams(newActivity, testStreamID), ShouldBeEmpty)
+
+		Convey("When the stream is read", func() {
+			stream, err := asUnderTest.GetStream(testStreamID, 0, 0, activitystream.After)
+			So(err, ShouldBeNil)
+
+			Convey("It should be sorted by score instead of time", func() {
+				So(len(stream), ShouldEqual, 2)
+				So(stream[0].Id, ShouldEqual, oldActivity.Id)
+				So(stream[1].Id, ShouldEqual, newActivity.Id)
+			})
+			Convey("It should page by score", func() {
+				_, next := activitystream.CreateScoredTokens(1, activitystream.After, stream[:1], testStreamID, scorer)
+				So(next, ShouldEqual, fmt.Sprintf("?s=1&after=%d", scorer.Score(testStreamID, oldActivity)))
+
+				page, err := asUnderTest.GetStream(testStreamID, 1, int(scorer.Score(testStreamID, oldActivity)), activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(page), ShouldEqual, 1)
+				So(page[0].Id, ShouldEqual, newActivity.Id)
+			})
+		})
+
+		Convey("When the Scorer is replaced and the stream rescored", func() {
+			asUnderTest.SetScorer(nil)
+			err := asUnderTest.Rescore(testStreamID)
+			So(err, ShouldBeNil)
+
+			Convey("It should be sorted by the new scores", func() {
+				stream, err := asUnderTest.GetStream(testStreamID, 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 2)
+				So(stream[0].Id, ShouldEqual, newActivity.Id)
+				So(stream[1].Id, ShouldEqual, oldActivity.Id)
+			})
+		})
+	})
+}
+
+func TestResolver(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	asUnderTest := RedisActivityStream{}
+	asUnderTest.Init(protocol, address)
+
+	testStreamID := "RESOLVER_STREAM_ID"
+	testActivity := createTestActivity()
+	testActivity.Actor.DisplayName = "Old Name"
+	calls := 0
+	resolver := activitystream.ResolverFunc(func(ids ...string) (map[string]activitystream.BaseObject, error) {
+		calls++
+		return map[string]activitystream.BaseObject{"ACTOR_ID": {Id: "ACTOR_ID", DisplayName: "New Name"}}, nil
+	})
+
+	Convey("Subject: Test hydration of activities on read", t, func() {
+		defer removeFromRedis(testStreamID, testActivity.Id)
+		So(asUnderTest.AddToStreams(testActivity, testStreamID), ShouldBeEmpty)
+		asUnderTest.SetResolver(resolver)
+		defer asUnderTest.SetResolver(nil)
+		calls = 0
+
+		Convey("When activities are read through GetStream, Get and BulkGet", func() {
+			stream, err := asUnderTest.GetStream(testStreamID, 10, 0, activitystream.After)
+			So(err, ShouldBeNil)
+			activity, err := asUnderTest.Get(testActivity.Id)
+			So(err, ShouldBeNil)
+			results, err := asUnderTest.BulkGetResults("SOME_INEXISTENT_KEY", testActivity.Id)
+			So(err, ShouldBeNil)
+
+			Convey("It should return the current version of the actor", func() {
+				So(stream[0].Actor.DisplayName, ShouldEqual, "New Name")
+				So(activity.Actor.DisplayName, ShouldEqual, "New Name")
+				So(results[0].Err, ShouldEqual, activitystream.ErrNotFound)
+				So(results[1].Activity.Actor.DisplayName, ShouldEqual, "New Name")
+				So(calls, ShouldEqual, 3)
+			})
+			Convey("It should keep the stored version", func() {
+				asUnderTest.SetResolver(nil)
+				activity, err := asUnderTest.Get(testActivity.Id)
+				So(err, ShouldBeNil)
+				So(activity.Actor.DisplayName, ShouldEqual, "Old Name")
+			})
+		})
+	})
+}
+
 // ************* HELPER METHODS *************
 func createTestActivity() activitystream.Activity {
 	var a activitystream.Activity
@@ -448,5 +660,5 @@ func removeFromRedis(ids ...string) {
 	for _, id := range ids {
 		c.Send("DEL", id)
 	}
-	c.Flush()
+	c.Do("")
 }
@@ -0,0 +1,6 @@
+-- Decrements the references KEYS[2] of the activity KEYS[1] removed from a stream and deletes it along with its
+-- history KEYS[3] if it is no longer referenced and ARGV[1] is "1".
+if redis.call("EXISTS",KEYS[2])==1 and redis.call("DECR",KEYS[2])<=0 and ARGV[1]=="1" then
+	redis.call("DEL",KEYS[1],KEYS[2],KEYS[3])
+end
+return 0
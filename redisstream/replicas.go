@@ -0,0 +1,137 @@
+package redisstream
+
+import (
+	"errors"
+	redis "github.com/garyburd/redigo/redis"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replication are the pools of the replicas and the last writes of the callers within the read-your-writes window
+type replication struct {
+	pools  []*redis.Pool
+	window time.Duration
+	// next is the number of the last replica read from, replicas are read from in turn
+	next uint32
+
+	mutex  sync.Mutex
+	writes map[string]time.Time
+	// pruneAt is the number of callers at which writes outside of the window are removed
+	pruneAt int
+}
+
+// SetReplicas sets the connection pools of the replicas which serve Get, BulkGet, BulkGetResults, GetStream and
+// GetStreamForViewer, in turn. All other commands are sent to the primary, configured by Init or InitWithConfig.
+// As replicas lag behind the primary, a caller may not read what it has just written, see SetReadYourWrites.
+// Replicas are not supported on a cluster.
+func (as *RedisActivityStream) SetReplicas(pools ...*redis.Pool) {
+	as.setReplication(pools, as.readYourWritesWindow())
+}
+
+// SetReadYourWrites sets the duration for which a caller reads from the primary after writing, 0 disables it.
+// Every command a caller sends to the primary counts as write. Callers are distinguished through ForCaller, all
+// callers without ID share the same window.
+func (as *RedisActivityStream) SetReadYourWrites(window time.Duration) {
+	var pools []*redis.Pool
+	if as.replication != nil {
+		pools = as.replication.pools
+	}
+	as.setReplication(pools, window)
+}
+
+// ForCaller returns a RedisActivityStream which reads from the primary within the read-your-writes window after the
+// caller, e.g. a user, has written through any RedisActivityStream of the same caller and tenant. It shares the
+// connection pools and copies all settings like ForTenant.
+func (as *RedisActivityStream) ForCaller(callerId string) *RedisActivityStream {
+	caller := *as
+	caller.callerId = callerId
+	return &caller
+}
+
+// WithReplicas reads from the replicas, see SetReplicas
+func WithReplicas(pools ...*redis.Pool) Option {
+	return func(as *RedisActivityStream) error {
+		for _, pool := range pools {
+			if pool == nil {
+				return errors.New("invalid option, the pool of a replica is nil")
+			}
+		}
+		as.SetReplicas(pools...)
+		return nil
+	}
+}
+
+// WithReadYourWrites reads from the primary after writing, see SetReadYourWrites
+func WithReadYourWrites(window time.Duration) Option {
+	return func(as *RedisActivityStream) error {
+		if window < 0 {
+			return errors.New("invalid option, the read-your-writes window is negative")
+		}
+		as.SetReadYourWrites(window)
+		return nil
+	}
+}
+
+// setReplication replaces the replication, so that RedisActivityStreams of other tenants and callers keep theirs
+func (as *RedisActivityStream) setReplication(pools []*redis.Pool, window time.Duration) {
+	as.replication = &replication{pools: pools, window: window, writes: make(map[string]time.Time)}
+}
+
+// readYourWritesWindow returns the read-your-writes window, 0 if none is set
+func (as *RedisActivityStream) readYourWritesWindow() time.Duration {
+	if as.replication == nil {
+		return 0
+	}
+	return as.replication.window
+}
+
+// readConn returns a connection of the next replica, or of the primary if there are no replicas or the caller has
+// written within the read-your-writes window. It must be closed after use.
+func (as *RedisActivityStream) readConn() redis.Conn {
+	r := as.replication
+	if r == nil || len(r.pools) == 0 || as.cluster != nil || r.wroteWithinWindow(as.tenant+as.callerId) {
+		return as.primaryConn()
+	}
+	next := atomic.AddUint32(&r.next, 1)
+	return conn{r.pools[int(next%uint32(len(r.pools)))].Get()}
+}
+
+// read executes a command like execute, but on a connection returned by readConn
+func (as *RedisActivityStream) read(cmd string, args ...interface{}) (interface{}, error) {
+	c := as.readConn()
+	defer c.Close()
+
+	return c.Do(cmd, args...)
+}
+
+// wrote records a write of the caller, if there is a read-your-writes window
+func (r *replication) wrote(caller string) {
+	if r.window <= 0 || len(r.pools) == 0 {
+		return
+	}
+	now := time.Now()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.writes[caller] = now
+	if len(r.writes) < r.pruneAt {
+		return
+	}
+	for c, t := range r.writes {
+		if now.Sub(t) >= r.window {
+			delete(r.writes, c)
+		}
+	}
+	r.pruneAt = 2*len(r.writes) + 1
+}
+
+// wroteWithinWindow returns whether the caller has written within the read-your-writes window
+func (r *replication) wroteWithinWindow(caller string) bool {
+	if r.window <= 0 {
+		return false
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	t, ok := r.writes[caller]
+	return ok && time.Since(t) < r.window
+}
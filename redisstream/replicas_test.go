@@ -0,0 +1,92 @@
+package redisstream
+
+import (
+	"encoding/json"
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+	"time"
+)
+
+func TestReplicas(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	// the databases 2 and 3 stand in for replicas, which have not replicated any write of the primary yet
+	replicas := make([]*redis.Pool, 2)
+	for i := range replicas {
+		replicas[i], _ = Config{Protocol: protocol, Addr: address, DB: 2 + i}.newPool()
+		defer replicas[i].Close()
+	}
+	testStreamID := "REPLICA_STREAM_ID"
+	testActivity := createTestActivity()
+	cleanUp := func() {
+		removeFromRedis(testStreamID, testActivity.Id, testActivity.Id+referencesSuffix)
+		for _, replica := range replicas {
+			c := replica.Get()
+			c.Do("DEL", testActivity.Id)
+			c.Close()
+		}
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test reading from replicas", t, func() {
+		cleanUp()
+		asUnderTest, err := NewRedisActivityStream(WithAddr(protocol, address), WithReplicas(replicas...), WithReadYourWrites(200*time.Millisecond))
+		So(err, ShouldBeNil)
+		alice := asUnderTest.ForCaller("alice")
+		bob := asUnderTest.ForCaller("bob")
+		So(alice.AddToStreams(testActivity, testStreamID), ShouldBeEmpty)
+
+		Convey("When the caller reads after writing", func() {
+			Convey("It should read from the primary", func() {
+				activity, err := alice.Get(testActivity.Id)
+				So(err, ShouldBeNil)
+				So(activity.Id, ShouldEqual, testActivity.Id)
+				stream, err := alice.GetStream(testStreamID, 10, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 1)
+			})
+		})
+		Convey("When another caller reads", func() {
+			Convey("It should read from a replica", func() {
+				_, err := bob.Get(testActivity.Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+				stream, err := bob.GetStream(testStreamID, 10, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(stream, ShouldBeEmpty)
+			})
+		})
+		Convey("When the window has passed", func() {
+			time.Sleep(250 * time.Millisecond)
+
+			Convey("It should read from a replica", func() {
+				_, err := alice.Get(testActivity.Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+			})
+		})
+		Convey("When the activity has been replicated to one replica", func() {
+			a, err := json.Marshal(testActivity)
+			So(err, ShouldBeNil)
+			c := replicas[0].Get()
+			_, err = c.Do("SET", testActivity.Id, a)
+			c.Close()
+			So(err, ShouldBeNil)
+
+			Convey("It should read from the replicas in turn", func() {
+				results, err := bob.BulkGetResults(testActivity.Id)
+				So(err, ShouldBeNil)
+				_, err = bob.Get(testActivity.Id)
+				So((results[0].Err == nil) != (err == nil), ShouldBeTrue)
+			})
+		})
+		Convey("When replicas are configured for a cluster", func() {
+			_, err := NewRedisActivityStream(WithCluster(protocol, address), WithReplicas(replicas...))
+
+			Convey("It should return an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
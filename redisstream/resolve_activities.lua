@@ -0,0 +1,86 @@
+-- Resolves a page of the stream KEYS[1] in the direction ARGV[5]:
+-- "" the first page, newest first, along with its pinned activities
+-- 		ZREVRANGE 5444ccbae3c1290013000004-out 0 1
+-- "after" the page after the score ARGV[2], newest first
+-- 		ZREVRANGEBYSCORE 5444ccbae3c1290013000004-out 1421679584 -inf LIMIT 1 2
+-- "before" the page before the score ARGV[2], oldest first
+-- 		ZRANGEBYSCORE 5444ccbae3c1290013000004-out 1421679584 +inf LIMIT 1 2
+-- Pages through the stream until ARGV[1] activities are collected, a limit of 0 returns the whole stream. Activities
+-- pinned to the stream in the sorted set KEYS[2] are left out and returned separately on the first page. Activities
+-- are stored under their ID prefixed by ARGV[3].
+-- If a viewer ARGV[6] is given, activities of actors contained in one of the sets KEYS[3..n], activities not
+-- addressed to the viewer and entries which are no JSON object are left out. Members of a collection are stored in
+-- the set ARGV[4]..ID..ARGV[7].
+-- It returns the pinned activities and the page.
+local direction=ARGV[5]
+local function fetch(offset,count)
+	if direction=="after" then
+		return redis.call("ZREVRANGEBYSCORE",KEYS[1],ARGV[2],"-inf","LIMIT",offset+1,count)
+	elseif direction=="before" then
+		return redis.call("ZRANGEBYSCORE",KEYS[1],ARGV[2],"+inf","LIMIT",offset+1,count)
+	end
+	if count<0 then return redis.call("ZREVRANGE",KEYS[1],offset,-1) end
+	return redis.call("ZREVRANGE",KEYS[1],offset,offset+count-1)
+end
+local pins={}
+if direction=="" then pins=redis.call("ZREVRANGE",KEYS[2],0,-1) end
+local limit=tonumber(ARGV[1])
+local viewer=ARGV[6]
+local hasPins=redis.call("EXISTS",KEYS[2])==1
+local muting=table.getn(KEYS)>2 and redis.call("EXISTS",unpack(KEYS,3))>0
+local public={["https://www.w3.org/ns/activitystreams#Public"]=true,["as:Public"]=true,["Public"]=true}
+local function addressed(activity)
+	local audience=false
+	for _,field in ipairs({"to","cc","bto","bcc"}) do
+		if type(activity[field])=="table" then
+			for _,id in ipairs(activity[field]) do
+				audience=true
+				if type(id)=="string" and ((viewer~="" and id==viewer) or public[id]) then return true end
+				if type(id)=="string" and redis.call("SISMEMBER",ARGV[4]..id..ARGV[7],viewer)==1 then return true end
+			end
+		end
+	end
+	return not audience
+end
+local function visible(raw)
+	local ok,activity=pcall(cjson.decode,raw)
+	if not ok or type(activity)~="table" then return false end
+	local actor=activity.actor
+	if type(actor)=="table" and type(actor.id)=="string" then
+		if viewer~="" and actor.id==viewer then return true end
+		for i=3,table.getn(KEYS) do
+			if muting and redis.call("SISMEMBER",KEYS[i],actor.id)==1 then return false end
+		end
+	end
+	return addressed(activity)
+end
+local function resolve(ids,result)
+	if table.getn(ids)==0 then return end
+	local keys={}
+	for i=1,table.getn(ids) do keys[i]=ARGV[3]..ids[i] end
+	local activities=redis.call("MGET",unpack(keys))
+	for i=1,table.getn(activities) do
+		if activities[i] and (viewer==nil or visible(activities[i])) then table.insert(result,activities[i]) end
+	end
+end
+local pinned={}
+resolve(pins,pinned)
+local result={}
+local offset=0
+while true do
+	local count=-1
+	if limit>0 then count=limit-table.getn(result) end
+	local ids=fetch(offset,count)
+	if table.getn(ids)==0 then break end
+	offset=offset+table.getn(ids)
+	local unpinned=ids
+	if hasPins then
+		unpinned={}
+		for i=1,table.getn(ids) do
+			if not redis.call("ZSCORE",KEYS[2],ids[i]) then table.insert(unpinned,ids[i]) end
+		end
+	end
+	resolve(unpinned,result)
+	if count<0 or table.getn(result)>=limit or table.getn(ids)<count then break end
+end
+return {pinned,result}
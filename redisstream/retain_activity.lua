@@ -0,0 +1,4 @@
+-- Increments the references KEYS[1] of an activity added to a stream or pinned to it, if they are counted, see
+-- add_to_streams.lua. It returns the number of references, 0 if they are not counted.
+if redis.call("EXISTS",KEYS[1])==0 then return 0 end
+return redis.call("INCR",KEYS[1])
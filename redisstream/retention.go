@@ -0,0 +1,90 @@
+package redisstream
+
+import (
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	"strconv"
+	"time"
+)
+
+// referencesKey returns the key of the number of streams referencing the activity
+func (as *RedisActivityStream) referencesKey(activityId string) string {
+	return as.activityKey(activityId) + referencesSuffix
+}
+
+const (
+	referencesSuffix = "-refs"
+	historySuffix    = "-history"
+)
+
+// SetRetentionPolicies sets the policies which define how long activities are kept in streams and in the database.
+// The first policy matching a stream applies to it, see activitystream.RetentionPolicy.
+// Important: Policies will not affect existing streams unless a new element is added or they are trimmed through Trim.
+func (as *RedisActivityStream) SetRetentionPolicies(policies ...activitystream.RetentionPolicy) {
+	as.retention = policies
+}
+
+// policy returns the retention policy of the stream with its maximum size resolved and capped by the limits of the
+// tenant
+func (as *RedisActivityStream) policy(streamId string) activitystream.RetentionPolicy {
+	policy, _ := as.retention.For(streamId)
+	if policy.MaxSize == 0 {
+		policy.MaxSize = as.maxStreamSize - 1
+	}
+	return as.limits.Limit(policy)
+}
+
+// activityTTL returns the TTL of an activity added to the streams, 0 if it is kept forever
+func (as *RedisActivityStream) activityTTL(streamIds []string) time.Duration {
+	var ttl time.Duration
+	for _, streamId := range streamIds {
+		policy := as.policy(streamId)
+		if policy.ActivityTTL <= 0 {
+			return 0
+		}
+		if policy.ActivityTTL > ttl {
+			ttl = policy.ActivityTTL
+		}
+	}
+	return ttl
+}
+
+// trimArgs returns the arguments of add_to_streams.lua which trim the stream
+func (as *RedisActivityStream) trimArgs(streamId string) []interface{} {
+	policy := as.policy(streamId)
+	keep := 0
+	if policy.MaxSize > 0 {
+		keep = policy.MaxSize
+	}
+	minScore := "-inf"
+	if policy.MaxAge > 0 {
+		minScore = strconv.FormatInt(activitystream.MakeTimestamp(time.Now().Add(-policy.MaxAge)), 10)
+	}
+	gc := "0"
+	if policy.CollectGarbage {
+		gc = "1"
+	}
+	return []interface{}{keep, minScore, gc}
+}
+
+// Trim applies the maximum size and age to the stream right away, which is otherwise done when an activity is added.
+// It returns the number of removed activities.
+func (as *RedisActivityStream) Trim(streamId string) (int, error) {
+	if err := as.checkKeys(as.streamKey(streamId)); err != nil {
+		return 0, err
+	}
+	if as.cluster != nil {
+		status, err := as.addToStreamsOnCluster(activitystream.Activity{}, nil, []string{streamId})
+		if err != nil {
+			return 0, err
+		}
+		return status[1], nil
+	}
+	args := []interface{}{3, "", "", as.streamKey(streamId), "", 0, referencesSuffix, historySuffix, "",
+		as.activityKey(""), 0}
+	reply, err := redis.Ints(as.eval(scriptAddToStreams, append(args, as.trimArgs(streamId)...)...))
+	if err != nil {
+		return 0, err
+	}
+	return reply[1], nil
+}
@@ -0,0 +1,164 @@
+package redisstream
+
+import (
+	"errors"
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+	"time"
+)
+
+func TestRetentionPolicies(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	asUnderTest := RedisActivityStream{}
+	asUnderTest.Init(protocol, address)
+
+	outbox := "RETENTION_ACTOR_ID-out"
+	inbox := "RETENTION_ACTOR_ID"
+	oldActivity := createTestActivity()
+	oldActivity.Published = time.Now().UTC().Add(-2 * time.Hour)
+	newActivity := createTestActivity()
+	cleanUp := func() {
+		removeFromRedis(outbox, inbox)
+		for _, id := range []string{oldActivity.Id, newActivity.Id} {
+			removeFromRedis(id, asUnderTest.referencesKey(id), asUnderTest.historyKey(id))
+		}
+	}
+	defer cleanUp()
+	defer asUnderTest.SetRetentionPolicies()
+
+	Convey("Subject: Test retention policies of streams", t, func() {
+		cleanUp()
+		asUnderTest.SetRetentionPolicies()
+
+		Convey("When a stream has a maximum age", func() {
+			So(asUnderTest.AddToStreams(oldActivity, outbox), ShouldBeEmpty)
+			asUnderTest.SetRetentionPolicies(activitystream.RetentionPolicy{Pattern: "*-out", MaxAge: time.Hour})
+			So(asUnderTest.AddToStreams(newActivity, outbox), ShouldBeEmpty)
+
+			Convey("It should remove older activities once a new one is added", func() {
+				stream, err := asUnderTest.GetStream(outbox, 0, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 1)
+				So(stream[0].Id, ShouldEqual, newActivity.Id)
+			})
+			Convey("It should keep removed activities in the database", func() {
+				_, err := asUnderTest.Get(oldActivity.Id)
+				So(err, ShouldBeNil)
+			})
+			Convey("It should reject activities which are too old", func() {
+				errs := asUnderTest.AddToStreams(oldActivity, outbox, inbox)
+				So(len(errs), ShouldEqual, 1)
+				So(errors.Is(errs[0], activitystream.ErrExpired), ShouldBeTrue)
+			})
+		})
+		Convey("When a stream has an activity TTL", func() {
+			asUnderTest.SetRetentionPolicies(
+				activitystream.RetentionPolicy{Pattern: "*-out", ActivityTTL: time.Hour},
+				activitystream.RetentionPolicy{Pattern: inbox, ActivityTTL: 2 * time.Hour},
+			)
+
+			Convey("It should let new activities expire after the longest TTL of their streams", func() {
+				So(asUnderTest.AddToStreams(newActivity, outbox, inbox), ShouldBeEmpty)
+				ttl, err := redis.Int64(asUnderTest.execute("PTTL", newActivity.Id))
+				So(err, ShouldBeNil)
+				So(ttl, ShouldBeBetween, int64(time.Hour/time.Millisecond), int64(2*time.Hour/time.Millisecond)+1)
+			})
+			Convey("It should keep the TTL when the activity is updated", func() {
+				So(asUnderTest.AddToStreams(newActivity, outbox), ShouldBeEmpty)
+				So(asUnderTest.Update(newActivity, ""), ShouldBeNil)
+				ttl, err := redis.Int64(asUnderTest.execute("PTTL", newActivity.Id))
+				So(err, ShouldBeNil)
+				So(ttl, ShouldBeGreaterThan, 0)
+				ttl, err = redis.Int64(asUnderTest.execute("PTTL", asUnderTest.historyKey(newActivity.Id)))
+				So(err, ShouldBeNil)
+				So(ttl, ShouldBeGreaterThan, 0)
+			})
+			Convey("It should keep the TTL when the activity is stored again", func() {
+				So(asUnderTest.AddToStreams(newActivity, outbox), ShouldBeEmpty)
+				So(asUnderTest.Store(newActivity), ShouldBeNil)
+				ttl, err := redis.Int64(asUnderTest.execute("PTTL", newActivity.Id))
+				So(err, ShouldBeNil)
+				So(ttl, ShouldBeGreaterThan, 0)
+			})
+			Convey("It should keep activities forever which are added to a stream without TTL", func() {
+				So(asUnderTest.AddToStreams(newActivity, outbox, "RETENTION_OTHER_STREAM_ID"), ShouldBeEmpty)
+				defer removeFromRedis("RETENTION_OTHER_STREAM_ID")
+				ttl, err := redis.Int64(asUnderTest.execute("PTTL", newActivity.Id))
+				So(err, ShouldBeNil)
+				So(ttl, ShouldEqual, -1)
+			})
+		})
+		Convey("When a stream collects garbage", func() {
+			asUnderTest.SetRetentionPolicies(activitystream.RetentionPolicy{Pattern: "*", MaxAge: time.Hour, CollectGarbage: true})
+			asUnderTest.SetMaxStreamSize(1)
+			defer asUnderTest.SetMaxStreamSize(activitystream.DefaultMaxStreamSize)
+			recentActivity := createTestActivity()
+			recentActivity.Published = time.Now().UTC().Add(-time.Minute)
+			defer removeFromRedis(recentActivity.Id, asUnderTest.referencesKey(recentActivity.Id))
+			So(asUnderTest.AddToStreams(recentActivity, outbox, inbox), ShouldBeEmpty)
+			So(asUnderTest.AddToStreams(newActivity, outbox), ShouldBeEmpty)
+
+			Convey("It should keep activities which are still referenced by another stream", func() {
+				_, err := asUnderTest.Get(recentActivity.Id)
+				So(err, ShouldBeNil)
+			})
+			Convey("It should delete activities which are no longer referenced", func() {
+				So(asUnderTest.AddToStreams(newActivity, inbox), ShouldBeEmpty)
+				_, err := asUnderTest.Get(recentActivity.Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+				exists, err := redis.Bool(asUnderTest.execute("EXISTS", asUnderTest.referencesKey(recentActivity.Id)))
+				So(err, ShouldBeNil)
+				So(exists, ShouldBeFalse)
+			})
+		})
+
+		Convey("When streams have different maximum sizes", func() {
+			audit := "RETENTION_AUDIT_ID"
+			defer removeFromRedis(audit)
+			asUnderTest.SetMaxStreamSize(1)
+			defer asUnderTest.SetMaxStreamSize(activitystream.DefaultMaxStreamSize)
+			asUnderTest.SetRetentionPolicies(
+				activitystream.RetentionPolicy{Pattern: "*-out", MaxSize: 2},
+				activitystream.RetentionPolicy{Pattern: "RETENTION_AUDIT_*", MaxSize: -1},
+			)
+			activities := []activitystream.Activity{createTestActivity(), createTestActivity(), createTestActivity()}
+			for i := range activities {
+				activities[i].Published = time.Now().UTC().Add(time.Duration(i) * time.Millisecond)
+				defer removeFromRedis(activities[i].Id, asUnderTest.referencesKey(activities[i].Id))
+				asUnderTest.AddToStreams(activities[i], outbox, inbox, audit)
+			}
+
+			Convey("It should trim each stream to its own maximum", func() {
+				So(streamSize(&asUnderTest, outbox), ShouldEqual, 2)
+				So(streamSize(&asUnderTest, inbox), ShouldEqual, 1)
+				So(streamSize(&asUnderTest, audit), ShouldEqual, 3)
+			})
+			Convey("When the maximum is decreased and the stream is trimmed", func() {
+				asUnderTest.SetRetentionPolicies(activitystream.RetentionPolicy{Pattern: "RETENTION_AUDIT_*", MaxSize: 1})
+				removed, err := asUnderTest.Trim(audit)
+				So(err, ShouldBeNil)
+
+				Convey("It should apply the new maximum right away", func() {
+					So(removed, ShouldEqual, 2)
+					stream, err := asUnderTest.GetStream(audit, 0, 0, activitystream.After)
+					So(err, ShouldBeNil)
+					So(len(stream), ShouldEqual, 1)
+					So(stream[0].Id, ShouldEqual, activities[2].Id)
+				})
+			})
+		})
+	})
+}
+
+// streamSize returns the number of activities in the stream
+func streamSize(as *RedisActivityStream, streamId string) int {
+	size, err := redis.Int(as.execute("ZCARD", streamId))
+	if err != nil {
+		panic(err)
+	}
+	return size
+}
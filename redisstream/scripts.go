@@ -0,0 +1,122 @@
+package redisstream
+
+import (
+	"embed"
+	redis "github.com/garyburd/redigo/redis"
+	"strings"
+)
+
+// scriptFiles are the Lua scripts, documented in their files
+//
+//go:embed *.lua
+var scriptFiles embed.FS
+
+// scripts is the registry of all Lua scripts by the name of their file
+var scripts = make(map[string]*redis.Script)
+
+// The Lua scripts are invoked through EVALSHA, which falls back to EVAL if Redis has not loaded them yet, e.g. after a
+// restart. The number of keys is passed as first argument.
+var (
+	scriptResolve         = registerScript("resolve_activities.lua")
+	scriptAddToStreams    = registerScript("add_to_streams.lua")
+	scriptUpdateActivity  = registerScript("update_activity.lua")
+	scriptDeleteActivity  = registerScript("delete_activity.lua")
+	scriptDeleteOrphans   = registerScript("delete_orphans.lua")
+	scriptStoreActivity   = registerScript("store_activity.lua")
+	scriptAddToStream     = registerScript("add_to_stream.lua")
+	scriptReleaseActivity = registerScript("release_activity.lua")
+	scriptRetainActivity  = registerScript("retain_activity.lua")
+)
+
+// registerScript adds the embedded script to the registry
+func registerScript(name string) *redis.Script {
+	src, err := scriptFiles.ReadFile(name)
+	if err != nil {
+		panic("redisstream: script " + name + " is not embedded")
+	}
+	script := redis.NewScript(-1, string(src))
+	scripts[name] = script
+	return script
+}
+
+// LoadScripts loads all Lua scripts through SCRIPT LOAD into Redis, into every node of a cluster and into all replicas.
+// Otherwise every script is sent once along with its first call. NewRedisActivityStream loads the scripts.
+func (as *RedisActivityStream) LoadScripts() error {
+	var conns []redis.Conn
+	if as.cluster != nil {
+		for _, addr := range as.cluster.masters() {
+			conns = append(conns, conn{as.cluster.pool(addr).Get()})
+		}
+	} else {
+		conns = append(conns, as.primaryConn())
+	}
+	if as.replication != nil {
+		for _, pool := range as.replication.pools {
+			conns = append(conns, conn{pool.Get()})
+		}
+	}
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+
+	for _, c := range conns {
+		for _, script := range scripts {
+			if err := script.Load(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// eval invokes the script on the primary
+func (as *RedisActivityStream) eval(script *redis.Script, keysAndArgs ...interface{}) (interface{}, error) {
+	c := as.conn()
+	defer c.Close()
+
+	return script.Do(c, keysAndArgs...)
+}
+
+// evalRead invokes the script on a connection returned by readConn, it must not write
+func (as *RedisActivityStream) evalRead(script *redis.Script, keysAndArgs ...interface{}) (interface{}, error) {
+	c := as.readConn()
+	defer c.Close()
+
+	return script.Do(c, keysAndArgs...)
+}
+
+// scriptCall is a script along with its keys and arguments
+type scriptCall struct {
+	script      *redis.Script
+	keysAndArgs []interface{}
+}
+
+// evalPipelined pipelines the scripts through EVALSHA and returns their replies in order, or the first error. Scripts
+// which Redis has not loaded are invoked again through EVAL once all replies have been received, so that they may be
+// invoked in any order.
+func evalPipelined(c redis.Conn, calls []scriptCall) ([]interface{}, error) {
+	for _, call := range calls {
+		if err := call.script.SendHash(c, call.keysAndArgs...); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]interface{}, len(calls))
+	errs := make([]error, len(calls))
+	for i := range calls {
+		replies[i], errs[i] = c.Receive()
+	}
+	for i, call := range calls {
+		if e, ok := errs[i].(redis.Error); ok && strings.HasPrefix(e.Error(), "NOSCRIPT ") {
+			replies[i], errs[i] = call.script.Do(c, call.keysAndArgs...)
+		}
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+	}
+	return replies, nil
+}
@@ -0,0 +1,157 @@
+package redisstream
+
+import (
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/fs"
+	"strings"
+	"sync"
+	testing "testing"
+	"time"
+)
+
+// recordingConn records the commands sent through the connection
+type recordingConn struct {
+	redis.Conn
+	record func(cmd string)
+}
+
+func (c recordingConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	c.record(cmd)
+	return c.Conn.Do(cmd, args...)
+}
+
+func (c recordingConn) Send(cmd string, args ...interface{}) error {
+	c.record(cmd)
+	return c.Conn.Send(cmd, args...)
+}
+
+func TestScripts(t *testing.T) {
+	Convey("Subject: Test the registry of Lua scripts", t, func() {
+		Convey("It should contain every script file", func() {
+			files, err := fs.Glob(scriptFiles, "*.lua")
+			So(err, ShouldBeNil)
+			So(len(files), ShouldEqual, len(scripts))
+			for _, file := range files {
+				So(scripts[file], ShouldNotBeNil)
+			}
+		})
+	})
+}
+
+func TestEvalSha(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	var mutex sync.Mutex
+	commands := make([]string, 0)
+	pool := &redis.Pool{
+		MaxIdle: 1,
+		Dial: func() (redis.Conn, error) {
+			c, err := redis.Dial(protocol, address)
+			return recordingConn{Conn: c, record: func(cmd string) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				commands = append(commands, strings.ToUpper(cmd))
+			}}, err
+		},
+	}
+	defer pool.Close()
+	testStreamID := "SCRIPT_STREAM_ID"
+	testActivities := make([]activitystream.Activity, 3)
+	start := time.Now().UTC()
+	for i := range testActivities {
+		testActivities[i] = createTestActivity()
+		testActivities[i].Published = start.Add(time.Duration(i) * time.Millisecond)
+	}
+	cleanUp := func() {
+		removeFromRedis(testStreamID)
+		for _, activity := range testActivities {
+			removeFromRedis(activity.Id, activity.Id+referencesSuffix, activity.Id+historySuffix)
+		}
+	}
+	defer cleanUp()
+	flushScripts := func() error {
+		c, err := redis.Dial(protocol, address)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		_, err = c.Do("SCRIPT", "FLUSH")
+		return err
+	}
+	resetCommands := func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		commands = commands[:0]
+	}
+	countCommands := func(cmd string) int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		n := 0
+		for _, c := range commands {
+			if c == cmd {
+				n++
+			}
+		}
+		return n
+	}
+
+	Convey("Subject: Test invoking Lua scripts through EVALSHA", t, func() {
+		cleanUp()
+		asUnderTest, err := NewRedisActivityStream(WithPool(pool))
+		So(err, ShouldBeNil)
+		for i := range testActivities {
+			So(asUnderTest.AddToStreams(testActivities[i], testStreamID), ShouldBeEmpty)
+		}
+
+		Convey("When the scripts have been loaded", func() {
+			resetCommands()
+			stream, err := asUnderTest.GetStream(testStreamID, 10, 0, activitystream.After)
+			So(err, ShouldBeNil)
+			So(len(stream), ShouldEqual, 3)
+
+			Convey("It should not send their source", func() {
+				So(countCommands("EVALSHA"), ShouldEqual, 1)
+				So(countCommands("EVAL"), ShouldEqual, 0)
+			})
+		})
+		Convey("When Redis has flushed the scripts", func() {
+			So(flushScripts(), ShouldBeNil)
+			resetCommands()
+			asUnderTest.SetRetentionPolicies(activitystream.RetentionPolicy{Pattern: testStreamID, MaxSize: 2, CollectGarbage: true})
+			trimmed, err := asUnderTest.Trim(testStreamID)
+			So(err, ShouldBeNil)
+			stream, err := asUnderTest.GetStream(testStreamID, 10, 0, activitystream.After)
+			So(err, ShouldBeNil)
+
+			Convey("It should send them again", func() {
+				So(trimmed, ShouldEqual, 1)
+				So(len(stream), ShouldEqual, 2)
+				So(countCommands("EVAL"), ShouldEqual, 2)
+			})
+		})
+		Convey("When pipelined scripts have been flushed", func() {
+			orphaned := createTestActivity()
+			orphaned.Published = start.Add(-time.Hour)
+			testActivities = append(testActivities, orphaned)
+			So(asUnderTest.Store(orphaned), ShouldBeNil)
+			So(flushScripts(), ShouldBeNil)
+			c := asUnderTest.conn()
+			defer c.Close()
+			replies, err := evalPipelined(c, []scriptCall{
+				{scriptDeleteOrphans, []interface{}{1, orphaned.Id, referencesSuffix, historySuffix, ""}},
+				{scriptReleaseActivity, []interface{}{3, "SCRIPT_MISSING_ID", "SCRIPT_MISSING_ID" + referencesSuffix, "SCRIPT_MISSING_ID" + historySuffix, "1"}},
+			})
+
+			Convey("It should invoke them again", func() {
+				So(err, ShouldBeNil)
+				deleted, err := redis.Strings(replies[0], nil)
+				So(err, ShouldBeNil)
+				So(deleted, ShouldResemble, []string{orphaned.Id})
+				So(replies[1], ShouldEqual, 0)
+			})
+		})
+	})
+}
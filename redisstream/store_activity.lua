@@ -0,0 +1,12 @@
+-- Stores the activity ARGV[1] as KEYS[1] unless it exists, like add_to_streams.lua on a single node. A new activity
+-- expires after ARGV[2] milliseconds unless it is 0. It returns 1 if the references of the activity are counted in
+-- KEYS[2].
+if redis.call("SET",KEYS[1],ARGV[1],"NX") then
+	redis.call("SET",KEYS[2],0)
+	local ttl=tonumber(ARGV[2])
+	if ttl>0 then
+		redis.call("PEXPIRE",KEYS[1],ttl)
+		redis.call("PEXPIRE",KEYS[2],ttl)
+	end
+end
+return redis.call("EXISTS",KEYS[2])
@@ -0,0 +1,109 @@
+package redisstream
+
+import (
+	"errors"
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	"strings"
+)
+
+// tenantPrefix starts the keys of every tenant, followed by the escaped tenant ID and ":"
+const tenantPrefix = "tenant:"
+
+// tenantIdEscaper escapes ":" in tenant IDs, so that the keys of no tenant start with the prefix of another
+var tenantIdEscaper = strings.NewReplacer(`\`, `\\`, `:`, `\:`)
+
+// globEscaper escapes the special characters of patterns matched by SCAN
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// ForTenant returns a RedisActivityStream whose keys are isolated from those of other tenants by the prefix
+// "tenant:ID:", which precedes the Namespace. It shares the connection pool and copies all settings, later changes
+// of the settings do not affect one another. The streams of the tenant are capped by the limits set through
+// SetTenantLimits. ForTenant is cheap and may be called on every request.
+// Calling ForTenant on a tenant returns one of its sub-tenants, which is isolated within the tenant and capped by its
+// limits.
+// As the keys of tenants share the keyspace with those of the default Namespace, IDs whose keys would start with
+// "tenant:" are rejected.
+func (as *RedisActivityStream) ForTenant(tenantId string) activitystream.ActivityStream {
+	return as.forTenant(tenantId)
+}
+
+// forTenant returns the tenant as RedisActivityStream
+func (as *RedisActivityStream) forTenant(tenantId string) *RedisActivityStream {
+	tenant := *as
+	tenant.tenant = as.tenant + tenantPrefix + tenantIdEscaper.Replace(tenantId) + ":"
+	// a sub-tenant can not escape the limits of its tenant
+	tenant.limits = as.tenantLimits[tenantId].Within(as.limits)
+	tenant.tenantLimits = nil
+	return &tenant
+}
+
+// SetTenantLimits sets the limits applied to the streams of the tenant returned by ForTenant afterwards
+func (as *RedisActivityStream) SetTenantLimits(tenantId string, limits activitystream.TenantLimits) {
+	if as.tenantLimits == nil {
+		as.tenantLimits = make(map[string]activitystream.TenantLimits)
+	}
+	as.tenantLimits[tenantId] = limits
+}
+
+// DeleteTenant deletes all keys of the tenant including those of its sub-tenants and returns their number.
+// The keys are deleted in batches, activities which are added during the deletion may be kept.
+func (as *RedisActivityStream) DeleteTenant(tenantId string) (int, error) {
+	tenant := as.forTenant(tenantId)
+	c := as.conn()
+	defer c.Close()
+
+	deleted := 0
+	err := tenant.scan(DefaultGCBatchSize, func(keys []string) error {
+		// on a cluster the keys are deleted per slot
+		groups := as.slotGroups(keys)
+		for _, group := range groups {
+			args := make([]interface{}, len(group))
+			for i, k := range group {
+				args[i] = keys[k]
+			}
+			c.Send("DEL", args...)
+		}
+		if err := c.Flush(); err != nil {
+			return err
+		}
+		for range groups {
+			n, err := redis.Int(c.Receive())
+			if err != nil {
+				return err
+			}
+			deleted += n
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// scanArgs returns the arguments of SCAN which iterate over the keys of the tenant, or all keys if it is none
+func (as *RedisActivityStream) scanArgs(cursor string, count int) []interface{} {
+	args := []interface{}{cursor, "COUNT", count}
+	if as.tenant != "" {
+		args = append(args, "MATCH", globEscaper.Replace(as.tenant)+"*")
+	}
+	return args
+}
+
+// checkKeys returns an error if one of the keys belongs to a sub-tenant, as it was derived from an ID which starts with
+// tenantPrefix. Such IDs are rejected, as their keys would be those of a tenant.
+func (as *RedisActivityStream) checkKeys(keys ...string) error {
+	for _, key := range keys {
+		if _, own := as.ownKey(key); !own {
+			return errors.New("invalid ID, the key " + key + " is reserved for tenants")
+		}
+	}
+	return nil
+}
+
+// ownKey returns the key without the prefix of the tenant and false if it belongs to another tenant or a sub-tenant
+func (as *RedisActivityStream) ownKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, as.tenant) {
+		return "", false
+	}
+	name := strings.TrimPrefix(key, as.tenant)
+	return name, !strings.HasPrefix(name, tenantPrefix)
+}
@@ -0,0 +1,155 @@
+package redisstream
+
+import (
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	testing "testing"
+	"time"
+)
+
+func TestForTenant(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	asUnderTest := RedisActivityStream{}
+	asUnderTest.Init(protocol, address)
+	asUnderTest.SetTenantLimits("TENANT_A", activitystream.TenantLimits{MaxStreamSize: 2})
+
+	testStreamID := "TENANT_STREAM_ID"
+	tenantA := asUnderTest.ForTenant("TENANT_A")
+	tenantB := asUnderTest.ForTenant("TENANT_B")
+	// shares the prefix "tenant:TENANT_A:" unless ":" is escaped
+	tenantAB := asUnderTest.ForTenant("TENANT_A:B")
+	testActivities := make([]activitystream.Activity, 3)
+	start := time.Now().UTC()
+	for i := range testActivities {
+		testActivities[i] = createTestActivity()
+		testActivities[i].Published = start.Add(time.Duration(i) * time.Millisecond)
+	}
+	cleanUp := func() {
+		for _, tenantId := range []string{"TENANT_A", "TENANT_B", "TENANT_A:B"} {
+			_, err := asUnderTest.DeleteTenant(tenantId)
+			if err != nil {
+				panic(err)
+			}
+		}
+		removeFromRedis(testStreamID, testActivities[0].Id)
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test isolation of tenants", t, func() {
+		cleanUp()
+		for i := range testActivities {
+			So(tenantA.AddToStreams(testActivities[i], testStreamID), ShouldBeEmpty)
+		}
+		So(tenantAB.AddToStreams(testActivities[0], testStreamID), ShouldBeEmpty)
+
+		Convey("When another tenant reads the same IDs", func() {
+			Convey("It should not find the data of the tenant", func() {
+				_, err := tenantB.Get(testActivities[0].Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+				_, err = asUnderTest.Get(testActivities[0].Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+				stream, err := tenantB.GetStream(testStreamID, 10, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(stream, ShouldBeEmpty)
+			})
+		})
+		Convey("When the root uses IDs which start with the prefix of a tenant", func() {
+			reserved := "tenant:TENANT_A:" + testStreamID
+			reservedActivity := createTestActivity()
+			reservedActivity.Id = "tenant:TENANT_A:" + testActivities[0].Id
+
+			Convey("It should reject them instead of touching the keys of the tenant", func() {
+				So(asUnderTest.AddToStreams(testActivities[0], reserved), ShouldNotBeEmpty)
+				So(asUnderTest.Store(reservedActivity), ShouldNotBeNil)
+				_, err := asUnderTest.Get(reservedActivity.Id)
+				So(err, ShouldNotBeNil)
+				So(err, ShouldNotEqual, activitystream.ErrNotFound)
+				_, err = asUnderTest.GetStream(reserved, 10, 0, activitystream.After)
+				So(err, ShouldNotBeNil)
+
+				stream, err := tenantA.GetStream(testStreamID, 10, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 2)
+				activity, err := tenantA.Get(testActivities[0].Id)
+				So(err, ShouldBeNil)
+				So(activity.Id, ShouldEqual, testActivities[0].Id)
+			})
+		})
+		Convey("When the tenant reads its stream", func() {
+			stream, err := tenantA.GetStream(testStreamID, 10, 0, activitystream.After)
+			So(err, ShouldBeNil)
+
+			Convey("It should be capped by the limits of the tenant", func() {
+				So(len(stream), ShouldEqual, 2)
+				So(stream[0].Id, ShouldEqual, testActivities[2].Id)
+				So(stream[1].Id, ShouldEqual, testActivities[1].Id)
+			})
+		})
+		Convey("When a sub-tenant of a tenant with limits reads its stream", func() {
+			tenant := asUnderTest.forTenant("TENANT_A")
+			tenant.SetTenantLimits("SUB_WITH_LIMITS", activitystream.TenantLimits{MaxStreamSize: 5})
+			for _, sub := range []*RedisActivityStream{tenant.forTenant("SUB"), tenant.forTenant("SUB_WITH_LIMITS")} {
+				for i := range testActivities {
+					So(sub.AddToStreams(testActivities[i], testStreamID), ShouldBeEmpty)
+				}
+				stream, err := sub.GetStream(testStreamID, 10, 0, activitystream.After)
+				So(err, ShouldBeNil)
+
+				Convey("It should be capped by the limits of the tenant: "+sub.tenant, func() {
+					So(len(stream), ShouldEqual, 2)
+				})
+			}
+		})
+		Convey("When a tenant without limits reads its stream", func() {
+			So(tenantB.AddToStreams(testActivities[0], testStreamID), ShouldBeEmpty)
+			So(tenantB.AddToStreams(testActivities[1], testStreamID), ShouldBeEmpty)
+			So(tenantB.AddToStreams(testActivities[2], testStreamID), ShouldBeEmpty)
+			stream, err := tenantB.GetStream(testStreamID, 10, 0, activitystream.After)
+			So(err, ShouldBeNil)
+
+			Convey("It should apply the maximum size of the ActivityStream", func() {
+				So(len(stream), ShouldEqual, 3)
+			})
+		})
+		Convey("When a tenant is deleted", func() {
+			deleted, err := asUnderTest.DeleteTenant("TENANT_A")
+			So(err, ShouldBeNil)
+
+			Convey("It should delete all its keys", func() {
+				// 3 activities with their references, including the trimmed one, and the stream
+				So(deleted, ShouldEqual, 7)
+				_, err := tenantA.Get(testActivities[2].Id)
+				So(err, ShouldEqual, activitystream.ErrNotFound)
+			})
+			Convey("It should not touch other tenants", func() {
+				activity, err := tenantAB.Get(testActivities[0].Id)
+				So(err, ShouldBeNil)
+				So(activity.Id, ShouldEqual, testActivities[0].Id)
+			})
+		})
+		Convey("When a tenant is migrated to a Namespace", func() {
+			tenant := asUnderTest.forTenant("TENANT_A")
+			renames, err := tenant.MigrateIds(Namespace{}, NewNamespace("as"), MigrationIds{Streams: []string{testStreamID}}, false)
+			So(err, ShouldBeNil)
+			tenant.SetNamespace(NewNamespace("as"))
+
+			Convey("It should rename the keys within the tenant", func() {
+				So(renames[tenant.tenant+testStreamID], ShouldEqual, "tenant:TENANT_A:as:stream:"+testStreamID)
+				exists, err := redis.Bool(tenant.execute("EXISTS", "tenant:TENANT_A:as:act:"+testActivities[2].Id))
+				So(err, ShouldBeNil)
+				So(exists, ShouldBeTrue)
+				stream, err := tenant.GetStream(testStreamID, 10, 0, activitystream.After)
+				So(err, ShouldBeNil)
+				So(len(stream), ShouldEqual, 2)
+			})
+			Convey("It should leave out other tenants", func() {
+				activity, err := tenantAB.Get(testActivities[0].Id)
+				So(err, ShouldBeNil)
+				So(activity.Id, ShouldEqual, testActivities[0].Id)
+			})
+		})
+	})
+}
@@ -0,0 +1,86 @@
+package redisstream
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/chrisport/go-activitystream/activitystream"
+	redis "github.com/garyburd/redigo/redis"
+	"time"
+)
+
+// historyKey returns the key of the list of prior revisions of the activity, the newest first
+func (as *RedisActivityStream) historyKey(activityId string) string {
+	return as.activityKey(activityId) + historySuffix
+}
+
+// SetMaxHistorySize sets the number of prior revisions kept by Update for every activity.
+// Zero restores activitystream.DefaultMaxHistorySize, a negative number keeps all revisions.
+func (as *RedisActivityStream) SetMaxHistorySize(maxHistorySize int) {
+	as.maxHistorySize = maxHistorySize
+}
+
+// historySize returns the maximum size of the history, negative if there is none
+func (as *RedisActivityStream) historySize() int {
+	if as.maxHistorySize == 0 {
+		return activitystream.DefaultMaxHistorySize
+	}
+	return as.maxHistorySize
+}
+
+// Update replaces the stored activity with the same ID if its Version equals expectedVersion, otherwise
+// ErrVersionConflict is returned. An empty version stands for an activity which has never been updated.
+// Deleted activities can not be updated, ErrNotFound is returned for them.
+// Versions are compared verbatim, the updated activity is stored with the number of its revision as Version and
+// Updated set to now. Revisions are counted from the stored Version if it is an integer, otherwise from 0, e.g. the
+// update of version "2" is stored as "3" and the update of version "v2" as "1".
+// The replaced revision is kept and returned by History, up to the maximum size set by SetMaxHistorySize.
+// If a Validator is set, invalid activities are rejected with activitystream.ValidationErrors.
+func (as *RedisActivityStream) Update(activity activitystream.Activity, expectedVersion string) error {
+	if err := as.checkKeys(as.activityKey(activity.Id)); err != nil {
+		return err
+	}
+	if activity.Published.Unix() <= 0 {
+		// keep the time of publication, the script ensures the activity has not been changed in between
+		current, err := as.Get(activity.Id)
+		if err != nil {
+			return err
+		}
+		activity.Published = current.Published
+	}
+	if err := as.validator.Validate(&activity); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	// the script sets the version
+	activity.Version = ""
+	activity.Updated = &now
+	activity.Pinned = false
+	a, err := json.Marshal(activity)
+	if err != nil {
+		return activitystream.Wrap(activitystream.ErrInvalidActivity, errors.New("marshalling Activity failed, "+err.Error()))
+	}
+
+	status, err := redis.Int(as.eval(scriptUpdateActivity, 2, as.activityKey(activity.Id), as.historyKey(activity.Id), expectedVersion, a, as.historySize()))
+	switch {
+	case err != nil:
+		return err
+	case status == 0:
+		return activitystream.ErrNotFound
+	case status < 0:
+		return activitystream.Wrap(activitystream.ErrVersionConflict, errors.New("expected version \""+expectedVersion+"\" of "+activity.Id))
+	}
+	return nil
+}
+
+// History returns the prior revisions of the activity replaced by Update, the newest first. It is empty if the
+// activity has never been updated.
+func (as *RedisActivityStream) History(id string) ([]activitystream.Activity, error) {
+	if err := as.checkKeys(as.historyKey(id)); err != nil {
+		return nil, err
+	}
+	reply, err := redis.Values(as.execute("LRANGE", as.historyKey(id), 0, -1))
+	if err != nil {
+		return nil, err
+	}
+	return parseActivitiesFromResponse(reply), nil
+}
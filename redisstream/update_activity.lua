@@ -0,0 +1,35 @@
+-- Replaces the activity KEYS[1] by ARGV[2] if its version equals ARGV[1] and pushes the replaced revision to the
+-- history list KEYS[2], which expires along with the activity and keeps at most ARGV[3] revisions unless ARGV[3] is
+-- negative. ARGV[2] must not have a version, it is stored with the next revision as version. Revisions are counted in
+-- the stored activity, starting at its version if that is an integer. It returns 1 on success, 0 if the activity does
+-- not exist or has been deleted and -1 if the version does not match.
+-- If ARGV[4] is "1" the activity is stored like Store: the version is not compared and an activity which does not
+-- exist yet is stored with the version ARGV[5].
+local store=ARGV[4]=="1"
+local current=redis.call("GET",KEYS[1])
+if not current then
+	if not store then return 0 end
+	local new=ARGV[2]
+	if ARGV[5]~="" then new='{"version":'..cjson.encode(ARGV[5])..','..string.sub(new,2) end
+	redis.call("SET",KEYS[1],new)
+	return 1
+end
+local ok,activity=pcall(cjson.decode,current)
+if not ok or type(activity)~="table" then activity={} end
+if activity.deleted then return 0 end
+local version=activity.version
+if type(version)~="string" then version="" end
+if not store and version~=ARGV[1] then return -1 end
+local revision=activity.revision
+if type(revision)~="number" then
+	revision=0
+	if string.len(version)<=15 and string.match(version,"^%d+$") then revision=tonumber(version) end
+end
+revision=string.format("%d",revision+1)
+redis.call("LPUSH",KEYS[2],current)
+local maxHistory=tonumber(ARGV[3])
+if maxHistory>=0 then redis.call("LTRIM",KEYS[2],0,maxHistory-1) end
+redis.call("SET",KEYS[1],'{"revision":'..revision..',"version":"'..revision..'",'..string.sub(ARGV[2],2),"KEEPTTL")
+local ttl=redis.call("PTTL",KEYS[1])
+if ttl>0 then redis.call("PEXPIRE",KEYS[2],ttl) end
+return 1
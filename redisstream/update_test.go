@@ -0,0 +1,122 @@
+package redisstream
+
+import (
+	"errors"
+	"github.com/chrisport/go-activitystream/activitystream"
+	. "github.com/smartystreets/goconvey/convey"
+	"strconv"
+	testing "testing"
+	"time"
+)
+
+func TestUpdateAndHistory(t *testing.T) {
+	if skipIntegrationTests {
+		return
+	}
+	asUnderTest := RedisActivityStream{}
+	asUnderTest.Init(protocol, address)
+
+	testActivity := createTestActivity()
+	cleanUp := func() {
+		removeFromRedis(testActivity.Id, asUnderTest.historyKey(testActivity.Id))
+	}
+	defer cleanUp()
+
+	Convey("Subject: Test Update and History of activities", t, func() {
+		cleanUp()
+		So(asUnderTest.Store(testActivity), ShouldBeNil)
+
+		Convey("When an activity is updated twice with the expected versions", func() {
+			edited := testActivity
+			edited.Object.Content = "first edit"
+			edited.Published = time.Time{}
+			So(asUnderTest.Update(edited, ""), ShouldBeNil)
+			edited.Object.Content = "second edit"
+			So(asUnderTest.Update(edited, "1"), ShouldBeNil)
+
+			Convey("It should store the latest revision with the next version", func() {
+				res, err := asUnderTest.Get(testActivity.Id)
+				So(err, ShouldBeNil)
+				So(res.Object.Content, ShouldEqual, "second edit")
+				So(res.Version, ShouldEqual, "2")
+				So(res.Updated, ShouldNotBeNil)
+				So(res.Published.Equal(testActivity.Published), ShouldBeTrue)
+			})
+			Convey("It should keep the prior revisions, the newest first", func() {
+				history, err := asUnderTest.History(testActivity.Id)
+				So(err, ShouldBeNil)
+				So(len(history), ShouldEqual, 2)
+				So(history[0].Version, ShouldEqual, "1")
+				So(history[0].Object.Content, ShouldEqual, "first edit")
+				So(history[1].Version, ShouldEqual, "")
+				So(history[1].Updated, ShouldBeNil)
+			})
+			Convey("It should reject an update of an outdated version", func() {
+				err := asUnderTest.Update(edited, "1")
+				So(errors.Is(err, activitystream.ErrVersionConflict), ShouldBeTrue)
+
+				res, err := asUnderTest.Get(testActivity.Id)
+				So(err, ShouldBeNil)
+				So(res.Version, ShouldEqual, "2")
+			})
+		})
+		Convey("When an updated activity is stored again", func() {
+			So(asUnderTest.Update(testActivity, ""), ShouldBeNil)
+			So(asUnderTest.Store(testActivity), ShouldBeNil)
+
+			Convey("It should keep counting its revisions", func() {
+				res, err := asUnderTest.Get(testActivity.Id)
+				So(err, ShouldBeNil)
+				So(res.Version, ShouldEqual, "2")
+				err = asUnderTest.Update(testActivity, "1")
+				So(errors.Is(err, activitystream.ErrVersionConflict), ShouldBeTrue)
+			})
+		})
+		Convey("When an activity has never been updated", func() {
+			Convey("It should have an empty history", func() {
+				history, err := asUnderTest.History(testActivity.Id)
+				So(err, ShouldBeNil)
+				So(history, ShouldBeEmpty)
+			})
+		})
+		Convey("When an inexistent activity is updated", func() {
+			Convey("It should return ErrNotFound", func() {
+				So(asUnderTest.Update(createTestActivity(), ""), ShouldEqual, activitystream.ErrNotFound)
+			})
+		})
+		Convey("When the stored version is no integer", func() {
+			versioned := createTestActivity()
+			versioned.Version = "v2"
+			defer removeFromRedis(versioned.Id, asUnderTest.historyKey(versioned.Id))
+			So(asUnderTest.Store(versioned), ShouldBeNil)
+
+			Convey("It should compare it verbatim and count the revisions from 0", func() {
+				err := asUnderTest.Update(versioned, "v1")
+				So(errors.Is(err, activitystream.ErrVersionConflict), ShouldBeTrue)
+				So(asUnderTest.Update(versioned, "v2"), ShouldBeNil)
+				So(asUnderTest.Update(versioned, "1"), ShouldBeNil)
+
+				res, err := asUnderTest.Get(versioned.Id)
+				So(err, ShouldBeNil)
+				So(res.Version, ShouldEqual, "2")
+			})
+		})
+		Convey("When an activity is updated more often than the maximum size of its history", func() {
+			asUnderTest.SetMaxHistorySize(2)
+			defer asUnderTest.SetMaxHistorySize(0)
+			version := ""
+			for i := 1; i <= 3; i++ {
+				So(asUnderTest.Update(testActivity, version), ShouldBeNil)
+				version = strconv.Itoa(i)
+			}
+
+			Convey("It should only keep the newest revisions", func() {
+				history, err := asUnderTest.History(testActivity.Id)
+				So(err, ShouldBeNil)
+				So(len(history), ShouldEqual, 2)
+				So(history[0].Version, ShouldEqual, "2")
+				So(history[1].Version, ShouldEqual, "1")
+			})
+		})
+	})
+}